@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/impressions"
+	"github.com/google/uuid"
+)
+
+const (
+	impressionsFlushInterval = 10 * time.Second
+	impressionsMaxPending    = 10000
+	analyticsDefaultHours    = 7 * 24
+	analyticsMaxHours        = 90 * 24
+)
+
+type JsonImpressionBucket struct {
+	Hour        time.Time `json:"hour"`
+	Impressions int64     `json:"impressions"`
+}
+
+type JsonChirpAnalytics struct {
+	ChirpID     uuid.UUID              `json:"chirp_id"`
+	Impressions int64                  `json:"impressions"`
+	Hourly      []JsonImpressionBucket `json:"hourly"`
+}
+
+func (cfg *apiConfig) flushImpressions(ctx context.Context, batch impressions.Batch) error {
+	return cfg.DB.AddChirpImpressions(ctx, database.AddChirpImpressionsParams{
+		Hour:     batch.Hour,
+		ChirpIds: batch.ChirpIDs,
+		Counts:   batch.Counts,
+	})
+}
+
+// recordImpressions counts the chirps as seen by viewerId. Authors
+// looking at their own chirps don't count.
+func (cfg *apiConfig) recordImpressions(viewerId uuid.UUID, chirps ...database.Chirp) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if chirp.UserID != viewerId {
+			ids = append(ids, chirp.ID)
+		}
+	}
+	cfg.Impressions.Record(ids...)
+}
+
+// ChirpAnalyticsHandler shows the author how often a chirp was seen, in
+// hourly buckets over the last ?hours= hours (a week by default). Counts
+// reach the database every few seconds, so the newest views may be
+// missing.
+func (cfg *apiConfig) ChirpAnalyticsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeChirpsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	chirpId, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	hours := analyticsDefaultHours
+	if h := req.URL.Query().Get("hours"); h != "" {
+		hours, err = strconv.Atoi(h)
+		if err != nil || hours <= 0 {
+			respondWithError(res, 400, "invalid hours")
+			return
+		}
+		hours = min(hours, analyticsMaxHours)
+	}
+	// other users' chirps get the same 404 as missing ones, so this can't
+	// be used to find out which chirps of a protected account exist
+	chirp, err := cfg.DB.GetChirpById(req.Context(), chirpId)
+	if err == sql.ErrNoRows || (err == nil && chirp.UserID != userId) {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	total, err := cfg.DB.GetChirpImpressionTotal(req.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	since := time.Now().UTC().Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	buckets, err := cfg.DB.GetChirpImpressionsSince(req.Context(), database.GetChirpImpressionsSinceParams{
+		ChirpID: chirp.ID,
+		Hour:    since,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := JsonChirpAnalytics{
+		ChirpID:     chirp.ID,
+		Impressions: total,
+		Hourly:      []JsonImpressionBucket{},
+	}
+	for _, bucket := range buckets {
+		ResBody.Hourly = append(ResBody.Hourly, JsonImpressionBucket{
+			Hour:        bucket.Hour,
+			Impressions: bucket.Impressions,
+		})
+	}
+	respondWithPayload(res, 200, ResBody)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type JsonBlock struct {
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlockHandler blocks another user. Blocked users can't start a
+// conversation with the blocker or send messages into one they share.
+func (cfg *apiConfig) BlockHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	blockedId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(res, 404, "user not found")
+		return
+	}
+	if blockedId == userId {
+		respondWithError(res, 400, "cannot block yourself")
+		return
+	}
+	if _, err := cfg.DB.GetUserById(req.Context(), blockedId); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(res, 404, "user not found")
+			return
+		}
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if err := cfg.DB.CreateBlock(req.Context(), database.CreateBlockParams{
+		BlockerID: userId,
+		BlockedID: blockedId,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
+
+// UnblockHandler lifts a block
+func (cfg *apiConfig) UnblockHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	blockedId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.DeleteBlock(req.Context(), database.DeleteBlockParams{
+		BlockerID: userId,
+		BlockedID: blockedId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+// GetBlocksHandler lists the users the caller has blocked, oldest first
+func (cfg *apiConfig) GetBlocksHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	blocks, err := cfg.DB.GetBlocks(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonBlock{}
+	for _, block := range blocks {
+		ResBody = append(ResBody, JsonBlock{
+			BlockedID: block.BlockedID,
+			CreatedAt: block.CreatedAt,
+		})
+	}
+	respondWithPayload(res, 200, ResBody)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxConversationSize counts the creator as well
+	maxConversationSize = 8
+	maxMessageLength    = 1000
+)
+
+type JsonConversation struct {
+	ID             uuid.UUID   `json:"id"`
+	ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	UnreadCount    int64       `json:"unread_count"`
+}
+
+type JsonMessage struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	Cursor         string    `json:"cursor"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func jsonMessageFromDB(message database.Message) JsonMessage {
+	return JsonMessage{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+		Cursor:         strconv.FormatInt(message.Seq, 10),
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+// conversationForUser resolves the {conversationID} path value, answering
+// 404 when the caller is not one of its participants
+func (cfg *apiConfig) conversationForUser(res http.ResponseWriter, req *http.Request, userId uuid.UUID) (database.ConversationParticipant, bool) {
+	conversationId, err := uuid.Parse(req.PathValue("conversationID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return database.ConversationParticipant{}, false
+	}
+	participant, err := cfg.DB.GetConversationParticipant(req.Context(), database.GetConversationParticipantParams{
+		ConversationID: conversationId,
+		UserID:         userId,
+	})
+	if err == sql.ErrNoRows {
+		res.WriteHeader(404)
+		return database.ConversationParticipant{}, false
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return database.ConversationParticipant{}, false
+	}
+	return participant, true
+}
+
+// directConversationKey identifies the one-to-one conversation between a
+// and b, whichever of them starts it
+func directConversationKey(a, b uuid.UUID) sql.NullString {
+	low, high := a.String(), b.String()
+	if high < low {
+		low, high = high, low
+	}
+	return sql.NullString{String: low + ":" + high, Valid: true}
+}
+
+// CreateConversationHandler starts a one-to-one or small group conversation.
+// A one-to-one conversation that already exists is returned as is. Nobody
+// can start a conversation with someone who has blocked them.
+func (cfg *apiConfig) CreateConversationHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeMessagesWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	if !cfg.requireVerifiedEmail(res, req, userId, verifiedForMessages) {
+		return
+	}
+	ReqBody := struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+		Body           string      `json:"body"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if len(ReqBody.Body) > maxMessageLength {
+		respondWithError(res, 400, "Message is too long")
+		return
+	}
+
+	participants := []uuid.UUID{userId}
+	seen := map[uuid.UUID]bool{userId: true}
+	for _, id := range ReqBody.ParticipantIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := cfg.DB.GetUserById(req.Context(), id); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(res, 404, "user not found")
+				return
+			}
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		participants = append(participants, id)
+	}
+	if len(participants) < 2 {
+		respondWithError(res, 400, "a conversation needs at least one other participant")
+		return
+	}
+	if len(participants) > maxConversationSize {
+		respondWithError(res, 400, "too many participants")
+		return
+	}
+	blocked, err := cfg.DB.IsBlockedByAny(req.Context(), database.IsBlockedByAnyParams{
+		BlockedID:  userId,
+		BlockerIds: participants[1:],
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if blocked {
+		respondWithError(res, 403, "a participant has blocked you")
+		return
+	}
+
+	code := 201
+	var directKey sql.NullString
+	if len(participants) == 2 {
+		directKey = directConversationKey(participants[0], participants[1])
+	}
+	var conversation database.Conversation
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		conversation, err = q.CreateConversation(req.Context(), directKey)
+		switch {
+		case err == sql.ErrNoRows:
+			// the pair already has a conversation, perhaps one created
+			// by a request racing this one
+			code = 200
+			conversation, err = q.GetDirectConversation(req.Context(), directKey)
+			if err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			for _, id := range participants {
+				if err := q.AddConversationParticipant(req.Context(), database.AddConversationParticipantParams{
+					ConversationID: conversation.ID,
+					UserID:         id,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		if ReqBody.Body == "" {
+			return nil
+		}
+		if _, err := q.CreateMessage(req.Context(), database.CreateMessageParams{
+			ConversationID: conversation.ID,
+			SenderID:       userId,
+			Body:           ReqBody.Body,
+		}); err != nil {
+			return err
+		}
+		return q.TouchConversation(req.Context(), conversation.ID)
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, code, JsonConversation{
+		ID:             conversation.ID,
+		ParticipantIDs: participants,
+		CreatedAt:      conversation.CreatedAt,
+		UpdatedAt:      conversation.UpdatedAt,
+	})
+}
+
+func (cfg *apiConfig) GetConversationsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeMessagesRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	conversations, err := cfg.DB.GetUserConversations(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonConversation{}
+	for _, conversation := range conversations {
+		participants, err := cfg.DB.GetConversationParticipantIds(req.Context(), conversation.ID)
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		ResBody = append(ResBody, JsonConversation{
+			ID:             conversation.ID,
+			ParticipantIDs: participants,
+			CreatedAt:      conversation.CreatedAt,
+			UpdatedAt:      conversation.UpdatedAt,
+			UnreadCount:    conversation.UnreadCount,
+		})
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+// CreateMessageHandler sends a message, unless another participant has
+// blocked the sender
+func (cfg *apiConfig) CreateMessageHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeMessagesWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	if !cfg.requireVerifiedEmail(res, req, userId, verifiedForMessages) {
+		return
+	}
+	participant, ok := cfg.conversationForUser(res, req, userId)
+	if !ok {
+		return
+	}
+	ReqBody := struct {
+		Body string `json:"body"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if ReqBody.Body == "" {
+		respondWithError(res, 400, "Message is empty")
+		return
+	}
+	if len(ReqBody.Body) > maxMessageLength {
+		respondWithError(res, 400, "Message is too long")
+		return
+	}
+	blocked, err := cfg.DB.IsBlockedInConversation(req.Context(), database.IsBlockedInConversationParams{
+		ConversationID: participant.ConversationID,
+		UserID:         userId,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if blocked {
+		respondWithError(res, 403, "a participant has blocked you")
+		return
+	}
+	var message database.Message
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		message, err = q.CreateMessage(req.Context(), database.CreateMessageParams{
+			ConversationID: participant.ConversationID,
+			SenderID:       userId,
+			Body:           ReqBody.Body,
+		})
+		if err != nil {
+			return err
+		}
+		return q.TouchConversation(req.Context(), participant.ConversationID)
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 201, jsonMessageFromDB(message))
+}
+
+// GetMessagesHandler pages through a conversation's history, newest first
+func (cfg *apiConfig) GetMessagesHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeMessagesRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	participant, ok := cfg.conversationForUser(res, req, userId)
+	if !ok {
+		return
+	}
+	before, limit, err := parsePage(req)
+	if err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	messages, err := cfg.DB.GetMessages(req.Context(), database.GetMessagesParams{
+		ConversationID: participant.ConversationID,
+		Before:         before,
+		MaxItems:       int32(limit),
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := struct {
+		Messages       []JsonMessage `json:"messages"`
+		NextCursor     string        `json:"next_cursor"`
+		LastReadCursor string        `json:"last_read_cursor"`
+	}{
+		Messages:       []JsonMessage{},
+		LastReadCursor: strconv.FormatInt(participant.LastReadSeq, 10),
+	}
+	for _, message := range messages {
+		ResBody.Messages = append(ResBody.Messages, jsonMessageFromDB(message))
+	}
+	if len(messages) == limit {
+		ResBody.NextCursor = ResBody.Messages[len(messages)-1].Cursor
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+// MarkConversationReadHandler moves the caller's read marker forward to the
+// given cursor, or to the latest message when no cursor is sent
+func (cfg *apiConfig) MarkConversationReadHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeMessagesWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	participant, ok := cfg.conversationForUser(res, req, userId)
+	if !ok {
+		return
+	}
+	ReqBody := struct {
+		Cursor string `json:"cursor"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	upTo, err := parseCursor(ReqBody.Cursor)
+	if err != nil {
+		respondWithError(res, 400, "invalid cursor")
+		return
+	}
+	if err := cfg.DB.MarkConversationRead(req.Context(), database.MarkConversationReadParams{
+		UpTo:           upTo,
+		ConversationID: participant.ConversationID,
+		UserID:         userId,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	embedWidth  = 550
+	embedHeight = 250
+	// oEmbed consumers may cache responses this many seconds
+	oembedCacheAge = 3600
+	// embedCSP lets any site frame the page but keeps the page itself
+	// from loading scripts or anything besides images
+	embedCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src https: http:; frame-ancestors *; base-uri 'none'; form-action 'none'"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var embedTemplate = template.Must(template.ParseFS(templateFS, "templates/embed_chirp.html"))
+
+// chirpURLPrefixes are the paths under BASE_URL that name a single chirp
+var chirpURLPrefixes = []string{"/app/chirps/", "/api/chirps/", "/embed/chirps/"}
+
+type JsonOEmbed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	AuthorName   string `json:"author_name"`
+	AuthorURL    string `json:"author_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+// chirpIdFromURL extracts the chirp id from a URL of one of this server's
+// chirp pages
+func (cfg *apiConfig) chirpIdFromURL(raw string) (uuid.UUID, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host != cfg.federationHost() {
+		return uuid.Nil, false
+	}
+	for _, prefix := range chirpURLPrefixes {
+		if rest, ok := strings.CutPrefix(u.Path, prefix); ok {
+			id, err := uuid.Parse(strings.TrimSuffix(rest, "/"))
+			return id, err == nil
+		}
+	}
+	return uuid.Nil, false
+}
+
+// publicChirp loads a chirp anyone may see, reporting chirps from
+// protected accounts as missing
+func (cfg *apiConfig) publicChirp(req *http.Request, chirpId uuid.UUID) (JsonChirp, error) {
+	chirp, err := cfg.DB.GetVisibleChirpById(req.Context(), database.GetVisibleChirpByIdParams{
+		ID:       chirpId,
+		ViewerID: uuid.Nil,
+	})
+	if err != nil {
+		return JsonChirp{}, err
+	}
+	chirps := []JsonChirp{jsonChirpFromDB(chirp)}
+	if err := cfg.withPreviews(req.Context(), chirps); err != nil {
+		return JsonChirp{}, err
+	}
+	return chirps[0], nil
+}
+
+// OEmbedHandler answers oEmbed discovery for public chirps with a "rich"
+// response wrapping the chirp's embed page in an iframe
+func (cfg *apiConfig) OEmbedHandler(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		respondWithError(res, 501, "only the json format is supported")
+		return
+	}
+	chirpId, ok := cfg.chirpIdFromURL(query.Get("url"))
+	if !ok {
+		respondWithError(res, 404, "not a chirp URL")
+		return
+	}
+	width, height := embedWidth, embedHeight
+	if maxWidth, err := strconv.Atoi(query.Get("maxwidth")); err == nil && maxWidth > 0 {
+		width = min(width, maxWidth)
+	}
+	if maxHeight, err := strconv.Atoi(query.Get("maxheight")); err == nil && maxHeight > 0 {
+		height = min(height, maxHeight)
+	}
+	chirp, err := cfg.publicChirp(req, chirpId)
+	if err == sql.ErrNoRows {
+		respondWithError(res, 404, "chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	embedURL := cfg.BaseURL + "/embed/chirps/" + chirp.ID.String()
+	iframe := fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" style="border:0" sandbox="allow-popups allow-popups-to-escape-sandbox" loading="lazy" title="Chirp"></iframe>`,
+		template.HTMLEscapeString(embedURL), width, height)
+	respondWithPayload(res, 200, JsonOEmbed{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Chirpy",
+		ProviderURL:  cfg.BaseURL,
+		AuthorName:   chirp.UserID.String(),
+		AuthorURL:    cfg.profilePageURL(chirp.UserID),
+		HTML:         iframe,
+		Width:        width,
+		Height:       height,
+		CacheAge:     oembedCacheAge,
+	})
+}
+
+// EmbedChirpHandler renders a public chirp as a standalone page meant to
+// be shown in an iframe on other sites
+func (cfg *apiConfig) EmbedChirpHandler(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		http.NotFound(res, req)
+		return
+	}
+	chirp, err := cfg.publicChirp(req, chirpId)
+	if err == sql.ErrNoRows {
+		http.NotFound(res, req)
+		return
+	}
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	var buf bytes.Buffer
+	if err := embedTemplate.Execute(&buf, struct {
+		Chirp     JsonChirp
+		Permalink string
+	}{
+		Chirp:     chirp,
+		Permalink: cfg.chirpPageURL(chirp.ID),
+	}); err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("Content-Security-Policy", embedCSP)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	res.WriteHeader(200)
+	res.Write(buf.Bytes())
+}
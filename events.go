@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/eventbus"
+	"github.com/P-H-Pancholi/Chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+const eventBusChannel = "chirpy_events"
+
+// chirpEventData is the payload of chirp.created and chirp.deleted
+type chirpEventData struct {
+	Chirp           JsonChirp `json:"chirp"`
+	AuthorProtected bool      `json:"author_protected"`
+}
+
+type userEventData struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type notificationEventData struct {
+	UserID       uuid.UUID        `json:"user_id"`
+	Notification JsonNotification `json:"notification"`
+}
+
+func (cfg *apiConfig) publish(req *http.Request, typ string, data interface{}) {
+	event, err := eventbus.NewEvent(typ, data)
+	if err == nil {
+		err = cfg.Bus.Publish(req.Context(), event)
+	}
+	if err != nil {
+		log.Printf("error publishing %s event: %v", typ, err)
+	}
+	cfg.queueWebhooks(req.Context(), typ, data)
+}
+
+// publishChirpEvent announces a created or deleted chirp on the event bus
+func (cfg *apiConfig) publishChirpEvent(req *http.Request, typ string, chirp JsonChirp) {
+	author, err := cfg.DB.GetUserById(req.Context(), chirp.UserID)
+	if err != nil {
+		log.Printf("error publishing %s event for chirp %s: %v", typ, chirp.ID, err)
+		return
+	}
+	cfg.publish(req, typ, chirpEventData{
+		Chirp:           chirp,
+		AuthorProtected: author.IsProtected,
+	})
+}
+
+// publishNotification announces a freshly committed notification
+func (cfg *apiConfig) publishNotification(req *http.Request, n database.Notification) {
+	cfg.publish(req, eventbus.NotificationCreated, notificationEventData{
+		UserID:       n.UserID,
+		Notification: jsonNotificationFromDB(n),
+	})
+}
+
+// relayToStream feeds bus events, from this instance or any other, to the
+// local SSE and WebSocket subscribers
+func (cfg *apiConfig) relayToStream(event eventbus.Event) {
+	switch event.Type {
+	case eventbus.ChirpCreated, eventbus.ChirpDeleted:
+		data := chirpEventData{}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			log.Printf("error relaying %s event: %v", event.Type, err)
+			return
+		}
+		streamEvent := stream.Event{
+			ID:              event.ID,
+			Type:            stream.EventChirp,
+			AuthorID:        data.Chirp.UserID,
+			AuthorProtected: data.AuthorProtected,
+			Hashtags:        extractHashtags(data.Chirp.Body),
+		}
+		payload := interface{}(data.Chirp)
+		if event.Type == eventbus.ChirpDeleted {
+			streamEvent.Type = stream.EventTombstone
+			payload = struct {
+				ID     uuid.UUID `json:"id"`
+				UserID uuid.UUID `json:"user_id"`
+			}{
+				ID:     data.Chirp.ID,
+				UserID: data.Chirp.UserID,
+			}
+		}
+		dat, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("error relaying %s event: %v", event.Type, err)
+			return
+		}
+		streamEvent.Data = dat
+		cfg.Stream.Publish(streamEvent)
+	case eventbus.NotificationCreated:
+		data := notificationEventData{}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			log.Printf("error relaying %s event: %v", event.Type, err)
+			return
+		}
+		dat, err := json.Marshal(data.Notification)
+		if err != nil {
+			log.Printf("error relaying %s event: %v", event.Type, err)
+			return
+		}
+		cfg.Stream.Publish(stream.Event{
+			ID:          event.ID,
+			Type:        stream.EventNotification,
+			RecipientID: data.UserID,
+			Data:        dat,
+		})
+	}
+}
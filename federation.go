@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/activitypub"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	federationKeyBits   = 2048
+	federationTimeout   = 10 * time.Second
+	deliveryRetryBase   = 30 * time.Second
+	deliveryMaxAttempts = 10
+	inboxMaxBody        = 1 << 20
+	outboxSize          = 20
+)
+
+// errNotFederated is returned for users whose actor we don't publish
+var errNotFederated = errors.New("protected accounts do not federate")
+
+func (cfg *apiConfig) actorURL(userId uuid.UUID) string {
+	return cfg.BaseURL + "/ap/users/" + userId.String()
+}
+
+func (cfg *apiConfig) noteURL(chirpId uuid.UUID) string {
+	return cfg.BaseURL + "/ap/chirps/" + chirpId.String()
+}
+
+// federationHost is the host part of acct: handles on this server
+func (cfg *apiConfig) federationHost() string {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func respondWithActivity(res http.ResponseWriter, code int, payload interface{}) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.Header().Set("Content-Type", activitypub.ContentType)
+	res.WriteHeader(code)
+	res.Write(dat)
+}
+
+// federatedUser loads a user whose actor we publish. Protected accounts
+// are kept off the fediverse, as remote servers can't enforce approvals.
+func (cfg *apiConfig) federatedUser(ctx context.Context, userId uuid.UUID) (database.User, error) {
+	user, err := cfg.DB.GetUserById(ctx, userId)
+	if err != nil {
+		return user, err
+	}
+	if user.IsProtected {
+		return user, errNotFederated
+	}
+	return user, nil
+}
+
+// userKey returns the user's signing key, generating it on first use
+func (cfg *apiConfig) userKey(ctx context.Context, userId uuid.UUID) (database.UserKey, error) {
+	key, err := cfg.DB.GetUserKey(ctx, userId)
+	if err != sql.ErrNoRows {
+		return key, err
+	}
+	private, err := rsa.GenerateKey(rand.Reader, federationKeyBits)
+	if err != nil {
+		return key, err
+	}
+	privatePem, err := activitypub.EncodePrivateKey(private)
+	if err != nil {
+		return key, err
+	}
+	publicPem, err := activitypub.EncodePublicKey(&private.PublicKey)
+	if err != nil {
+		return key, err
+	}
+	return cfg.DB.CreateUserKey(ctx, database.CreateUserKeyParams{
+		UserID:        userId,
+		PublicKeyPem:  publicPem,
+		PrivateKeyPem: privatePem,
+	})
+}
+
+// deliver signs activity as userId and queues it for each inbox
+func (cfg *apiConfig) deliver(ctx context.Context, userId uuid.UUID, activity activitypub.Activity, inboxes ...string) error {
+	key, err := cfg.userKey(ctx, userId)
+	if err != nil {
+		return err
+	}
+	private, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	for _, inbox := range inboxes {
+		cfg.Deliveries.Enqueue(activitypub.Delivery{
+			Inbox: inbox,
+			Body:  body,
+			KeyID: cfg.actorURL(userId) + "#main-key",
+			Key:   private,
+		})
+	}
+	return nil
+}
+
+func (cfg *apiConfig) noteFromChirp(chirp JsonChirp) activitypub.Note {
+	return activitypub.Note{
+		ID:           cfg.noteURL(chirp.ID),
+		Type:         "Note",
+		AttributedTo: cfg.actorURL(chirp.UserID),
+		Content:      "<p>" + html.EscapeString(chirp.Body) + "</p>",
+		Published:    chirp.CreatedAt.UTC().Format(time.RFC3339),
+		URL:          cfg.chirpPageURL(chirp.ID),
+		To:           []string{activitypub.PublicCollection},
+	}
+}
+
+func (cfg *apiConfig) createActivity(chirp JsonChirp) (activitypub.Activity, error) {
+	note := cfg.noteFromChirp(chirp)
+	activity, err := activitypub.NewActivity(note.ID+"/activity", activitypub.TypeCreate, note.AttributedTo, note)
+	activity.Published = note.Published
+	activity.To = note.To
+	return activity, err
+}
+
+// federateChirp sends a Create or Delete for chirp to its author's remote
+// followers. It runs in the request rather than off the event bus so that
+// only the instance handling the request delivers.
+func (cfg *apiConfig) federateChirp(req *http.Request, typ string, chirp JsonChirp) {
+	ctx := req.Context()
+	if _, err := cfg.federatedUser(ctx, chirp.UserID); err != nil {
+		if err != errNotFederated {
+			log.Printf("error federating chirp %s: %v", chirp.ID, err)
+		}
+		return
+	}
+	inboxes, err := cfg.DB.GetRemoteFollowerInboxes(ctx, chirp.UserID)
+	if err != nil || len(inboxes) == 0 {
+		if err != nil {
+			log.Printf("error federating chirp %s: %v", chirp.ID, err)
+		}
+		return
+	}
+	var activity activitypub.Activity
+	if typ == activitypub.TypeDelete {
+		activity, err = activitypub.NewActivity(cfg.noteURL(chirp.ID)+"#delete", activitypub.TypeDelete, cfg.actorURL(chirp.UserID), activitypub.Tombstone{
+			ID:   cfg.noteURL(chirp.ID),
+			Type: "Tombstone",
+		})
+		activity.To = []string{activitypub.PublicCollection}
+	} else {
+		activity, err = cfg.createActivity(chirp)
+	}
+	if err == nil {
+		err = cfg.deliver(ctx, chirp.UserID, activity, inboxes...)
+	}
+	if err != nil {
+		log.Printf("error federating chirp %s: %v", chirp.ID, err)
+	}
+}
+
+// WebFingerHandler resolves acct:<user id>@<host> to the user's actor
+func (cfg *apiConfig) WebFingerHandler(res http.ResponseWriter, req *http.Request) {
+	resource := req.URL.Query().Get("resource")
+	name, host, err := activitypub.ParseAcct(resource)
+	if err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	userId, err := uuid.Parse(name)
+	if err != nil || host != cfg.federationHost() {
+		res.WriteHeader(404)
+		return
+	}
+	user, err := cfg.federatedUser(req.Context(), userId)
+	if err == sql.ErrNoRows || err == errNotFederated {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	dat, err := json.Marshal(activitypub.WebFinger{
+		Subject: "acct:" + user.ID.String() + "@" + host,
+		Aliases: []string{cfg.actorURL(user.ID)},
+		Links: []activitypub.WebFingerLink{{
+			Rel:  "self",
+			Type: activitypub.ContentType,
+			Href: cfg.actorURL(user.ID),
+		}},
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.Header().Set("Content-Type", activitypub.JRDContentType)
+	res.WriteHeader(200)
+	res.Write(dat)
+}
+
+// ActorHandler serves the Person document remote servers follow and
+// fetch the signing key from
+func (cfg *apiConfig) ActorHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	user, err := cfg.federatedUser(req.Context(), userId)
+	if err == sql.ErrNoRows || err == errNotFederated {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	key, err := cfg.userKey(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	actorURL := cfg.actorURL(user.ID)
+	respondWithActivity(res, 200, activitypub.Actor{
+		Context:           []string{activitypub.ActivityStreams, activitypub.SecurityContext},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: user.ID.String(),
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		PublicKey: activitypub.PublicKey{
+			ID:           actorURL + "#main-key",
+			Owner:        actorURL,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	})
+}
+
+// OutboxHandler lists the user's latest chirps as Create activities
+func (cfg *apiConfig) OutboxHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	user, err := cfg.federatedUser(req.Context(), userId)
+	if err == sql.ErrNoRows || err == errNotFederated {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	chirps, err := cfg.DB.GetRecentChirpsByUser(req.Context(), database.GetRecentChirpsByUserParams{
+		UserID: user.ID,
+		Limit:  outboxSize,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	items := []activitypub.Activity{}
+	for _, chirp := range chirps {
+		activity, err := cfg.createActivity(jsonChirpFromDB(chirp))
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		activity.Context = nil
+		items = append(items, activity)
+	}
+	respondWithActivity(res, 200, struct {
+		Context      string                 `json:"@context"`
+		ID           string                 `json:"id"`
+		Type         string                 `json:"type"`
+		TotalItems   int                    `json:"totalItems"`
+		OrderedItems []activitypub.Activity `json:"orderedItems"`
+	}{
+		Context:      activitypub.ActivityStreams,
+		ID:           cfg.actorURL(user.ID) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	})
+}
+
+// NoteHandler serves a public chirp as a Note
+func (cfg *apiConfig) NoteHandler(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	chirp, err := cfg.DB.GetChirpById(req.Context(), chirpId)
+	if err == nil {
+		_, err = cfg.federatedUser(req.Context(), chirp.UserID)
+	}
+	if err == sql.ErrNoRows || err == errNotFederated {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	note := cfg.noteFromChirp(jsonChirpFromDB(chirp))
+	note.Context = activitypub.ActivityStreams
+	respondWithActivity(res, 200, note)
+}
+
+// InboxHandler accepts signed activities from remote servers. Follow and
+// Undo{Follow} maintain the user's remote followers and Accept confirms a
+// follow we sent. Remote chirps aren't stored, so Create and Delete are
+// acknowledged and dropped. Chirps can't be liked, so Like is refused.
+func (cfg *apiConfig) InboxHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	user, err := cfg.federatedUser(req.Context(), userId)
+	if err == sql.ErrNoRows || err == errNotFederated {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(res, req.Body, inboxMaxBody))
+	if err != nil {
+		respondWithError(res, 413, "activity too large")
+		return
+	}
+	activity := activitypub.Activity{}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		respondWithError(res, 400, "invalid activity")
+		return
+	}
+	owner, err := activitypub.Verify(req, body, activitypub.ActorKeyLookup(cfg.APClient))
+	if err != nil {
+		respondWithError(res, 401, err.Error())
+		return
+	}
+	if owner != activity.Actor {
+		respondWithError(res, 401, "activity actor does not match signature")
+		return
+	}
+	actorURL := cfg.actorURL(user.ID)
+
+	switch activity.Type {
+	case activitypub.TypeFollow:
+		if activity.ObjectID() != actorURL {
+			respondWithError(res, 400, "follow is not for this actor")
+			return
+		}
+		remote, err := activitypub.FetchActor(req.Context(), cfg.APClient, activity.Actor)
+		if err != nil {
+			respondWithError(res, 502, err.Error())
+			return
+		}
+		// the Accept goes to the same inbox as every later delivery
+		inbox := remote.DeliveryInbox()
+		if err := cfg.DB.CreateRemoteFollower(req.Context(), database.CreateRemoteFollowerParams{
+			UserID:  user.ID,
+			ActorID: remote.ID,
+			Inbox:   inbox,
+		}); err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		activity.Context = nil
+		accept, err := activitypub.NewActivity(actorURL+"#accepts/"+uuid.NewString(), activitypub.TypeAccept, actorURL, activity)
+		if err == nil {
+			err = cfg.deliver(req.Context(), user.ID, accept, inbox)
+		}
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+	case activitypub.TypeUndo:
+		inner, err := activity.EmbeddedActivity()
+		if err != nil || inner.Type != activitypub.TypeFollow || inner.Actor != activity.Actor {
+			break
+		}
+		if err := cfg.DB.DeleteRemoteFollower(req.Context(), database.DeleteRemoteFollowerParams{
+			UserID:  user.ID,
+			ActorID: activity.Actor,
+		}); err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+	case activitypub.TypeLike:
+		log.Printf("refusing Like of %s from %s: chirps can't be liked", activity.ObjectID(), activity.Actor)
+		respondWithError(res, 422, "likes are not supported")
+		return
+	case activitypub.TypeAccept:
+		if _, err := cfg.DB.AcceptRemoteFollow(req.Context(), database.AcceptRemoteFollowParams{
+			UserID:  user.ID,
+			ActorID: activity.Actor,
+		}); err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+	}
+	res.WriteHeader(202)
+}
+
+// FederatedFollowHandler follows a remote account given as name@host
+func (cfg *apiConfig) FederatedFollowHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	ReqBody := struct {
+		Account string `json:"account"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if _, err := cfg.federatedUser(req.Context(), userId); err != nil {
+		respondWithError(res, 403, err.Error())
+		return
+	}
+	scheme, _, _ := strings.Cut(cfg.BaseURL, "://")
+	remote, err := activitypub.ResolveAccount(req.Context(), cfg.APClient, scheme, ReqBody.Account)
+	if err != nil {
+		respondWithError(res, 404, err.Error())
+		return
+	}
+	follow, err := cfg.DB.CreateRemoteFollow(req.Context(), database.CreateRemoteFollowParams{
+		UserID:  userId,
+		ActorID: remote.ID,
+		Inbox:   remote.Inbox,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	actorURL := cfg.actorURL(userId)
+	activity, err := activitypub.NewActivity(actorURL+"#follows/"+uuid.NewString(), activitypub.TypeFollow, actorURL, remote.ID)
+	if err == nil {
+		err = cfg.deliver(req.Context(), userId, activity, remote.Inbox)
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 202, struct {
+		ActorID  string `json:"actor_id"`
+		Accepted bool   `json:"accepted"`
+	}{
+		ActorID:  follow.ActorID,
+		Accepted: follow.Accepted,
+	})
+}
+
+// FederatedLikeHandler likes a remote Note given by its id
+func (cfg *apiConfig) FederatedLikeHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeChirpsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	ReqBody := struct {
+		Object string `json:"object"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if _, err := cfg.federatedUser(req.Context(), userId); err != nil {
+		respondWithError(res, 403, err.Error())
+		return
+	}
+	note := activitypub.Note{}
+	err = activitypub.FetchJSON(req.Context(), cfg.APClient, ReqBody.Object, activitypub.ContentType, &note)
+	if err != nil || note.ID == "" {
+		respondWithError(res, 404, "remote object not found")
+		return
+	}
+	author, err := activitypub.FetchActor(req.Context(), cfg.APClient, note.AttributedTo)
+	if err != nil {
+		respondWithError(res, 404, err.Error())
+		return
+	}
+	actorURL := cfg.actorURL(userId)
+	activity, err := activitypub.NewActivity(actorURL+"#likes/"+uuid.NewString(), activitypub.TypeLike, actorURL, note.ID)
+	if err == nil {
+		err = cfg.deliver(req.Context(), userId, activity, author.Inbox)
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(202)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/feed"
+	"github.com/google/uuid"
+)
+
+const (
+	feedSize       = 50
+	feedTitleRunes = 60
+)
+
+// splitFeedFile splits "name.atom" into its name and format, since
+// ServeMux wildcards have to span a whole path segment
+func splitFeedFile(file string, formats ...string) (string, string, bool) {
+	for _, format := range formats {
+		if name, ok := strings.CutSuffix(file, "."+format); ok && name != "" {
+			return name, format, true
+		}
+	}
+	return "", "", false
+}
+
+func feedEntryTitle(body string) string {
+	runes := []rune(body)
+	if len(runes) <= feedTitleRunes {
+		return body
+	}
+	return string(runes[:feedTitleRunes]) + "…"
+}
+
+// chirpsFeed builds a feed from chirps ordered newest first. link is the
+// web page the feed mirrors. An empty feed is dated fallbackUpdated so its
+// ETag stays stable.
+func (cfg *apiConfig) chirpsFeed(id, title, link, self string, fallbackUpdated time.Time, chirps []database.Chirp) feed.Feed {
+	f := feed.Feed{
+		ID:      id,
+		Title:   title,
+		Link:    link,
+		Self:    self,
+		Updated: fallbackUpdated,
+	}
+	for _, chirp := range chirps {
+		if chirp.UpdatedAt.After(f.Updated) || len(f.Entries) == 0 {
+			f.Updated = chirp.UpdatedAt
+		}
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:        "urn:uuid:" + chirp.ID.String(),
+			Link:      cfg.chirpPageURL(chirp.ID),
+			Title:     feedEntryTitle(chirp.Body),
+			Content:   chirp.Body,
+			Author:    chirp.UserID.String(),
+			Published: chirp.CreatedAt,
+			Updated:   chirp.UpdatedAt,
+		})
+	}
+	return f
+}
+
+// serveFeed renders f and answers conditional GETs through ETag and
+// Last-Modified, so feed readers get a 304 when nothing changed
+func serveFeed(res http.ResponseWriter, req *http.Request, format string, f feed.Feed) {
+	render, contentType := f.Atom, "application/atom+xml; charset=utf-8"
+	if format == "rss" {
+		render, contentType = f.RSS, "application/rss+xml; charset=utf-8"
+	}
+	dat, err := render()
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	sum := sha256.Sum256(dat)
+	res.Header().Set("Content-Type", contentType)
+	res.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(res, req, "", f.Updated, bytes.NewReader(dat))
+}
+
+// UserFeedHandler serves /feeds/users/{user_id}.atom and .rss. Protected
+// accounts have no public feed.
+func (cfg *apiConfig) UserFeedHandler(res http.ResponseWriter, req *http.Request) {
+	name, format, ok := splitFeedFile(req.PathValue("file"), "atom", "rss")
+	if !ok {
+		res.WriteHeader(404)
+		return
+	}
+	userId, err := uuid.Parse(name)
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	user, err := cfg.DB.GetUserById(req.Context(), userId)
+	if err == sql.ErrNoRows || (err == nil && user.IsProtected) {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	chirps, err := cfg.DB.GetRecentChirpsByUser(req.Context(), database.GetRecentChirpsByUserParams{
+		UserID: user.ID,
+		Limit:  feedSize,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	f := cfg.chirpsFeed(
+		"urn:uuid:"+user.ID.String(),
+		"Chirps by "+user.ID.String(),
+		cfg.profilePageURL(user.ID),
+		cfg.BaseURL+req.URL.Path,
+		user.CreatedAt,
+		chirps,
+	)
+	serveFeed(res, req, format, f)
+}
+
+// HashtagFeedHandler serves /feeds/hashtags/{tag}.atom with public chirps
+// carrying the hashtag
+func (cfg *apiConfig) HashtagFeedHandler(res http.ResponseWriter, req *http.Request) {
+	tag, format, ok := splitFeedFile(req.PathValue("file"), "atom")
+	if !ok || !validHashtag(tag) {
+		res.WriteHeader(404)
+		return
+	}
+	tag = strings.ToLower(tag)
+	chirps, err := cfg.DB.GetRecentPublicChirpsByHashtag(req.Context(), database.GetRecentPublicChirpsByHashtagParams{
+		Tag:      tag,
+		MaxItems: feedSize,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	f := cfg.chirpsFeed(
+		cfg.BaseURL+"/feeds/hashtags/"+tag,
+		"Chirps tagged #"+tag,
+		cfg.BaseURL+"/app/",
+		cfg.BaseURL+req.URL.Path,
+		time.Unix(0, 0),
+		chirps,
+	)
+	serveFeed(res, req, format, f)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/eventbus"
+	"github.com/google/uuid"
+)
+
+const (
+	followStatusPending  = "pending"
+	followStatusApproved = "approved"
+)
+
+type JsonFollow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func jsonFollowFromDB(follow database.Follow) JsonFollow {
+	return JsonFollow{
+		FollowerID: follow.FollowerID,
+		FolloweeID: follow.FolloweeID,
+		Status:     follow.Status,
+		CreatedAt:  follow.CreatedAt,
+	}
+}
+
+// FollowHandler follows another user. Following a protected account only
+// files a pending request which the owner has to approve.
+func (cfg *apiConfig) FollowHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	followeeId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(res, 404, "user not found")
+		return
+	}
+	if followeeId == userId {
+		respondWithError(res, 400, "cannot follow yourself")
+		return
+	}
+	followee, err := cfg.DB.GetUserById(req.Context(), followeeId)
+	if err == sql.ErrNoRows {
+		respondWithError(res, 404, "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	status := followStatusApproved
+	if followee.IsProtected {
+		status = followStatusPending
+	}
+	var (
+		follow       database.Follow
+		notification *database.Notification
+	)
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		follow, err = q.CreateFollow(req.Context(), database.CreateFollowParams{
+			FollowerID: userId,
+			FolloweeID: followee.ID,
+			Status:     status,
+		})
+		if err != nil {
+			return err
+		}
+		// repeated follows only bump updated_at, so they don't notify again
+		if !follow.CreatedAt.Equal(follow.UpdatedAt) {
+			return nil
+		}
+		notificationType := notificationFollow
+		if follow.Status == followStatusPending {
+			notificationType = notificationFollowRequest
+		}
+		n, err := q.CreateNotification(req.Context(), database.CreateNotificationParams{
+			UserID:  followee.ID,
+			Type:    notificationType,
+			ActorID: uuid.NullUUID{UUID: userId, Valid: true},
+		})
+		notification = &n
+		return err
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	// repeated follows only bump updated_at, so they don't announce again
+	if follow.CreatedAt.Equal(follow.UpdatedAt) {
+		cfg.publish(req, eventbus.FollowCreated, jsonFollowFromDB(follow))
+	}
+	if notification != nil {
+		cfg.publishNotification(req, *notification)
+	}
+	respondWithPayload(res, 201, jsonFollowFromDB(follow))
+}
+
+// UnfollowHandler removes a follow, or withdraws a pending follow request
+func (cfg *apiConfig) UnfollowHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	followeeId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.DeleteFollow(req.Context(), database.DeleteFollowParams{
+		FollowerID: userId,
+		FolloweeID: followeeId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+func (cfg *apiConfig) GetFollowRequestsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	requests, err := cfg.DB.GetPendingFollowRequests(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonFollow{}
+	for _, follow := range requests {
+		ResBody = append(ResBody, jsonFollowFromDB(follow))
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+func (cfg *apiConfig) ApproveFollowRequestHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	followerId, err := uuid.Parse(req.PathValue("followerID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	var notification *database.Notification
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		result, err := q.ApproveFollowRequest(req.Context(), database.ApproveFollowRequestParams{
+			FollowerID: followerId,
+			FolloweeID: userId,
+		})
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+		n, err := notifyFollowApproved(req.Context(), q, followerId, userId)
+		notification = &n
+		return err
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if notification == nil {
+		res.WriteHeader(404)
+		return
+	}
+	cfg.publishNotification(req, *notification)
+	res.WriteHeader(204)
+}
+
+// notifyFollowApproved tells followerId that followeeId approved their
+// follow request
+func notifyFollowApproved(ctx context.Context, q *database.Queries, followerId, followeeId uuid.UUID) (database.Notification, error) {
+	return q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  followerId,
+		Type:    notificationFollowApproved,
+		ActorID: uuid.NullUUID{UUID: followeeId, Valid: true},
+	})
+}
+
+func (cfg *apiConfig) DenyFollowRequestHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	followerId, err := uuid.Parse(req.PathValue("followerID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.DenyFollowRequest(req.Context(), database.DenyFollowRequestParams{
+		FollowerID: followerId,
+		FolloweeID: userId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+// respondToRowChange answers 204 when an :execresult query touched a row
+// and 404 when it matched nothing
+func respondToRowChange(res http.ResponseWriter, result sql.Result, err error) {
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if rowsAffected == 0 {
+		res.WriteHeader(404)
+		return
+	}
+	res.WriteHeader(204)
+}
@@ -13,6 +13,8 @@ require (
 	golang.org/x/crypto v0.36.0
 )
 
+require github.com/gorilla/websocket v1.5.3
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.1
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// hashtagPattern matches the same tags as the #(\w+) pattern used by the
+// SQL queries. Postgres's \w covers Unicode letters and digits in a UTF-8
+// database, while Go's is ASCII only, so the class is spelled out here to
+// find #café the same way in both.
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+var bareHashtagPattern = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+
+// validHashtag reports whether tag, without its leading #, is a whole
+// hashtag as extractHashtags would find it
+func validHashtag(tag string) bool {
+	return bareHashtagPattern.MatchString(tag)
+}
+
+// extractHashtags returns the distinct lower-cased hashtags in a chirp body
+func extractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
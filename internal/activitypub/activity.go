@@ -0,0 +1,235 @@
+// Package activitypub holds the pieces of ActivityPub federation that
+// don't depend on Chirpy's database: the vocabulary, HTTP signatures,
+// fetching remote actors and the outbound delivery queue
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	ContentType      = "application/activity+json"
+	JRDContentType   = "application/jrd+json"
+	ActivityStreams  = "https://www.w3.org/ns/activitystreams"
+	SecurityContext  = "https://w3id.org/security/v1"
+	PublicCollection = "https://www.w3.org/ns/activitystreams#Public"
+	// maxDocumentSize caps remote documents we are willing to read
+	maxDocumentSize = 1 << 20
+)
+
+// activity types
+const (
+	TypeCreate = "Create"
+	TypeDelete = "Delete"
+	TypeFollow = "Follow"
+	TypeLike   = "Like"
+	TypeAccept = "Accept"
+	TypeUndo   = "Undo"
+)
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+	Context           interface{} `json:"@context,omitempty"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername,omitempty"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox,omitempty"`
+	Followers         string      `json:"followers,omitempty"`
+	Endpoints         *Endpoints  `json:"endpoints,omitempty"`
+	PublicKey         PublicKey   `json:"publicKey"`
+}
+
+// DeliveryInbox prefers the actor's shared inbox when it has one
+func (a Actor) DeliveryInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+type Note struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	AttributedTo string      `json:"attributedTo"`
+	Content      string      `json:"content"`
+	Published    string      `json:"published"`
+	URL          string      `json:"url,omitempty"`
+	To           []string    `json:"to"`
+	Cc           []string    `json:"cc,omitempty"`
+}
+
+type Tombstone struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// Activity is used both ways. Object holds either an IRI string or an
+// embedded object.
+type Activity struct {
+	Context   interface{}     `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object"`
+	Published string          `json:"published,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+}
+
+// NewActivity builds an activity around object, which may be an IRI
+// string or any JSON-marshallable object
+func NewActivity(id, typ, actor string, object interface{}) (Activity, error) {
+	dat, err := json.Marshal(object)
+	if err != nil {
+		return Activity{}, err
+	}
+	return Activity{
+		Context: ActivityStreams,
+		ID:      id,
+		Type:    typ,
+		Actor:   actor,
+		Object:  dat,
+	}, nil
+}
+
+// ObjectID returns the id of the activity's object, whether it was sent
+// as a bare IRI or embedded
+func (a Activity) ObjectID() string {
+	var iri string
+	if err := json.Unmarshal(a.Object, &iri); err == nil {
+		return iri
+	}
+	embedded := struct {
+		ID string `json:"id"`
+	}{}
+	json.Unmarshal(a.Object, &embedded)
+	return embedded.ID
+}
+
+// EmbeddedActivity decodes the object as an activity, as in Undo{Follow}
+func (a Activity) EmbeddedActivity() (Activity, error) {
+	inner := Activity{}
+	err := json.Unmarshal(a.Object, &inner)
+	return inner, err
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+// ParseAcct splits "acct:name@host" or "name@host" into name and host
+func ParseAcct(resource string) (string, string, error) {
+	acct := strings.TrimPrefix(strings.TrimPrefix(resource, "acct:"), "@")
+	name, host, ok := strings.Cut(acct, "@")
+	if !ok || name == "" || host == "" {
+		return "", "", fmt.Errorf("activitypub: invalid account %q", resource)
+	}
+	return name, host, nil
+}
+
+// FetchJSON GETs an ActivityPub document from a remote server
+func FetchJSON(ctx context.Context, client *http.Client, url string, accept string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("activitypub: fetching %s: status %d", url, res.StatusCode)
+	}
+	dat, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize+1))
+	if err != nil {
+		return err
+	}
+	if len(dat) > maxDocumentSize {
+		return fmt.Errorf("activitypub: fetching %s: document too large", url)
+	}
+	return json.Unmarshal(dat, out)
+}
+
+// FetchActor dereferences a remote actor id
+func FetchActor(ctx context.Context, client *http.Client, id string) (Actor, error) {
+	actor := Actor{}
+	if err := FetchJSON(ctx, client, id, ContentType, &actor); err != nil {
+		return Actor{}, err
+	}
+	if actor.ID == "" || actor.Inbox == "" {
+		return Actor{}, errors.New("activitypub: remote actor has no id or inbox")
+	}
+	return actor, nil
+}
+
+// ResolveAccount looks up "name@host" through the host's WebFinger
+// endpoint and fetches the actor it points to
+func ResolveAccount(ctx context.Context, client *http.Client, scheme, acct string) (Actor, error) {
+	name, host, err := ParseAcct(acct)
+	if err != nil {
+		return Actor{}, err
+	}
+	jrd := WebFinger{}
+	url := fmt.Sprintf("%s://%s/.well-known/webfinger?resource=acct:%s@%s", scheme, host, name, host)
+	if err := FetchJSON(ctx, client, url, JRDContentType, &jrd); err != nil {
+		return Actor{}, err
+	}
+	for _, link := range jrd.Links {
+		if link.Rel == "self" && (link.Type == ContentType || strings.Contains(link.Type, "activitystreams")) {
+			return FetchActor(ctx, client, link.Href)
+		}
+	}
+	return Actor{}, errors.New("activitypub: webfinger has no actor link")
+}
+
+// ActorKeyLookup is a KeyLookup fetching the key's actor document. The
+// document has to be the actor it was fetched from and own the key, or
+// anyone could publish their own key under another actor's id.
+func ActorKeyLookup(client *http.Client) KeyLookup {
+	return func(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+		actorID, _, _ := strings.Cut(keyID, "#")
+		actor, err := FetchActor(ctx, client, actorID)
+		if err != nil {
+			return nil, "", err
+		}
+		if actor.ID != actorID || actor.PublicKey.Owner != actorID {
+			return nil, "", errors.New("activitypub: actor document is not the key's owner")
+		}
+		if actor.PublicKey.ID != keyID {
+			return nil, "", errors.New("activitypub: key not found on actor")
+		}
+		key, err := ParsePublicKey(actor.PublicKey.PublicKeyPem)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, actorID, nil
+	}
+}
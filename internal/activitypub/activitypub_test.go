@@ -0,0 +1,251 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func staticLookup(key *rsa.PublicKey, owner string) KeyLookup {
+	return func(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+		return key, owner, nil
+	}
+}
+
+func TestSignVerifyRoundtrip(t *testing.T) {
+	key := testKey(t)
+	body := []byte(`{"type":"Follow"}`)
+	req := httptest.NewRequest("POST", "https://chirpy.example/ap/users/1/inbox", bytes.NewReader(body))
+	assert.NoError(t, Sign(req, body, "https://remote.example/actor#main-key", key))
+
+	owner, err := Verify(req, body, staticLookup(&key.PublicKey, "https://remote.example/actor"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://remote.example/actor", owner)
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	key := testKey(t)
+	lookup := staticLookup(&key.PublicKey, "https://remote.example/actor")
+	body := []byte(`{"type":"Follow"}`)
+	sign := func() *http.Request {
+		req := httptest.NewRequest("POST", "https://chirpy.example/ap/users/1/inbox", bytes.NewReader(body))
+		assert.NoError(t, Sign(req, body, "https://remote.example/actor#main-key", key))
+		return req
+	}
+
+	_, err := Verify(sign(), []byte(`{"type":"Delete"}`), lookup)
+	assert.Error(t, err, "body changed")
+
+	req := sign()
+	req.URL.Path = "/ap/users/2/inbox"
+	_, err = Verify(req, body, lookup)
+	assert.Error(t, err, "target changed")
+
+	req = sign()
+	req.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	_, err = Verify(req, body, lookup)
+	assert.Error(t, err, "stale date")
+
+	_, err = Verify(sign(), body, staticLookup(&testKey(t).PublicKey, "https://remote.example/actor"))
+	assert.Error(t, err, "wrong key")
+
+	req = sign()
+	req.Header.Del("Signature")
+	_, err = Verify(req, body, lookup)
+	assert.Error(t, err, "unsigned")
+}
+
+func TestPEMRoundtrip(t *testing.T) {
+	key := testKey(t)
+	privPem, err := EncodePrivateKey(key)
+	assert.NoError(t, err)
+	pubPem, err := EncodePublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	parsedPriv, err := ParsePrivateKey(privPem)
+	assert.NoError(t, err)
+	assert.True(t, key.Equal(parsedPriv))
+	parsedPub, err := ParsePublicKey(pubPem)
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(parsedPub))
+}
+
+func TestActivityObjectID(t *testing.T) {
+	follow, err := NewActivity("https://a.example/follows/1", TypeFollow, "https://a.example/actor", "https://b.example/actor")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://b.example/actor", follow.ObjectID())
+
+	undo, err := NewActivity("https://a.example/undo/1", TypeUndo, "https://a.example/actor", follow)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://a.example/follows/1", undo.ObjectID())
+	inner, err := undo.EmbeddedActivity()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeFollow, inner.Type)
+}
+
+// remoteServer stands in for another fediverse server with one actor
+func remoteServer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	pubPem, err := EncodePublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	mux.HandleFunc("GET /.well-known/webfinger", func(w http.ResponseWriter, r *http.Request) {
+		host := strings.TrimPrefix(srv.URL, "http://")
+		if r.URL.Query().Get("resource") != "acct:alice@"+host {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", JRDContentType)
+		json.NewEncoder(w).Encode(WebFinger{
+			Subject: "acct:alice@" + host,
+			Links:   []WebFingerLink{{Rel: "self", Type: ContentType, Href: srv.URL + "/users/alice"}},
+		})
+	})
+	mux.HandleFunc("GET /users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Actor{
+			ID:        srv.URL + "/users/alice",
+			Type:      "Person",
+			Inbox:     srv.URL + "/users/alice/inbox",
+			Endpoints: &Endpoints{SharedInbox: srv.URL + "/inbox"},
+			PublicKey: PublicKey{
+				ID:           srv.URL + "/users/alice#main-key",
+				Owner:        srv.URL + "/users/alice",
+				PublicKeyPem: pubPem,
+			},
+		})
+	})
+	return srv
+}
+
+func TestResolveAccount(t *testing.T) {
+	srv := remoteServer(t, testKey(t))
+	actor, err := ResolveAccount(context.Background(), srv.Client(), "http", "alice@"+strings.TrimPrefix(srv.URL, "http://"))
+	assert.NoError(t, err)
+	assert.Equal(t, srv.URL+"/users/alice", actor.ID)
+	assert.Equal(t, srv.URL+"/inbox", actor.DeliveryInbox())
+
+	_, err = ResolveAccount(context.Background(), srv.Client(), "http", "bob@"+strings.TrimPrefix(srv.URL, "http://"))
+	assert.Error(t, err)
+}
+
+func TestActorKeyLookup(t *testing.T) {
+	key := testKey(t)
+	srv := remoteServer(t, key)
+	body := []byte(`{"type":"Like"}`)
+	req := httptest.NewRequest("POST", "https://chirpy.example/ap/users/1/inbox", bytes.NewReader(body))
+	assert.NoError(t, Sign(req, body, srv.URL+"/users/alice#main-key", key))
+
+	owner, err := Verify(req, body, ActorKeyLookup(srv.Client()))
+	assert.NoError(t, err)
+	assert.Equal(t, srv.URL+"/users/alice", owner)
+
+	req = httptest.NewRequest("POST", "https://chirpy.example/ap/users/1/inbox", bytes.NewReader(body))
+	assert.NoError(t, Sign(req, body, srv.URL+"/users/alice#other-key", key))
+	_, err = Verify(req, body, ActorKeyLookup(srv.Client()))
+	assert.Error(t, err)
+}
+
+func TestActorKeyLookupRejectsImpostors(t *testing.T) {
+	key := testKey(t)
+	pubPem, err := EncodePublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	victim := "https://victim.example/users/bob"
+	// one document claims to be someone else, the other claims someone
+	// else owns its key
+	mux.HandleFunc("GET /users/mallory", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Actor{
+			ID:        victim,
+			Inbox:     srv.URL + "/users/mallory/inbox",
+			PublicKey: PublicKey{ID: srv.URL + "/users/mallory#main-key", Owner: victim, PublicKeyPem: pubPem},
+		})
+	})
+	mux.HandleFunc("GET /users/eve", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Actor{
+			ID:        srv.URL + "/users/eve",
+			Inbox:     srv.URL + "/users/eve/inbox",
+			PublicKey: PublicKey{ID: srv.URL + "/users/eve#main-key", Owner: victim, PublicKeyPem: pubPem},
+		})
+	})
+
+	body := []byte(`{"type":"Follow"}`)
+	for _, keyID := range []string{srv.URL + "/users/mallory#main-key", srv.URL + "/users/eve#main-key"} {
+		req := httptest.NewRequest("POST", "https://chirpy.example/ap/users/1/inbox", bytes.NewReader(body))
+		assert.NoError(t, Sign(req, body, keyID, key))
+		_, err := Verify(req, body, ActorKeyLookup(srv.Client()))
+		assert.Error(t, err, keyID)
+	}
+}
+
+func TestQueueRetriesUntilDelivered(t *testing.T) {
+	key := testKey(t)
+	body := []byte(`{"type":"Create"}`)
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	delivered := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		n := attempts
+		mu.Unlock()
+		if n <= 2 {
+			w.WriteHeader(500)
+			return
+		}
+		dat, _ := io.ReadAll(r.Body)
+		_, err := Verify(r, dat, staticLookup(&key.PublicKey, "https://chirpy.example/ap/users/1"))
+		w.WriteHeader(202)
+		delivered <- err
+	}))
+	defer srv.Close()
+
+	q := NewQueue(srv.Client(), 10*time.Millisecond, 5)
+	defer q.Close()
+	q.Enqueue(Delivery{Inbox: srv.URL + "/inbox", Body: body, KeyID: "https://chirpy.example/ap/users/1#main-key", Key: key})
+
+	select {
+	case err := <-delivered:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery was not retried")
+	}
+	mu.Lock()
+	assert.Equal(t, 3, attempts)
+	mu.Unlock()
+}
+
+func TestDeliverPermanentFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(410)
+	}))
+	defer srv.Close()
+
+	err := Deliver(context.Background(), srv.Client(), Delivery{Inbox: srv.URL, Body: []byte(`{}`), KeyID: "k", Key: testKey(t)})
+	_, permanent := err.(*PermanentError)
+	assert.True(t, permanent)
+}
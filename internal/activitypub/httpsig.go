@@ -0,0 +1,206 @@
+package activitypub
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how far a signed request's Date may be from now
+const MaxClockSkew = 5 * time.Minute
+
+// KeyLookup resolves a signature's keyId to the public key and the id of
+// the actor owning it
+type KeyLookup func(ctx context.Context, keyID string) (key *rsa.PublicKey, owner string, err error)
+
+// Digest returns the Digest header value for a request body
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		case "host":
+			lines = append(lines, "host: "+requestHost(req))
+		default:
+			value := req.Header.Get(h)
+			if value == "" {
+				return "", fmt.Errorf("httpsig: missing signed header %s", h)
+			}
+			lines = append(lines, h+": "+value)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Sign adds Date, Digest (when there is a body) and a draft-cavage
+// Signature header to req, signed with key under keyID
+func Sign(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", Digest(body))
+		headers = append(headers, "digest")
+	}
+	toSign, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(toSign))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+func parseSignatureHeader(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[name] = strings.Trim(value, `"`)
+	}
+	return params
+}
+
+// Verify checks req's Signature header against the key returned by lookup
+// and returns the owner of that key. The signature has to cover the
+// request target, host and date, plus the digest of body for requests
+// that have one.
+func Verify(req *http.Request, body []byte, lookup KeyLookup) (string, error) {
+	params := parseSignatureHeader(req.Header.Get("Signature"))
+	keyID, sigB64 := params["keyId"], params["signature"]
+	if keyID == "" || sigB64 == "" {
+		return "", errors.New("httpsig: missing or malformed Signature header")
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return "", fmt.Errorf("httpsig: unsupported algorithm %s", alg)
+	}
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, r := range required {
+		found := false
+		for _, h := range headers {
+			found = found || h == r
+		}
+		if !found {
+			return "", fmt.Errorf("httpsig: %s is not signed", r)
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", errors.New("httpsig: invalid Date header")
+	}
+	if skew := time.Since(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return "", errors.New("httpsig: Date is too far from now")
+	}
+	if len(body) > 0 && req.Header.Get("Digest") != Digest(body) {
+		return "", errors.New("httpsig: Digest does not match body")
+	}
+
+	toVerify, err := signingString(req, headers)
+	if err != nil {
+		return "", err
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return "", errors.New("httpsig: signature is not base64")
+	}
+	key, owner, err := lookup(req.Context(), keyID)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(toVerify))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return "", errors.New("httpsig: signature does not verify")
+	}
+	return owner, nil
+}
+
+func EncodePrivateKey(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+func EncodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func ParsePrivateKey(pemData string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, errors.New("activitypub: invalid private key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("activitypub: private key is not RSA")
+	}
+	return rsaKey, nil
+}
+
+func ParsePublicKey(pemData string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, errors.New("activitypub: invalid public key PEM")
+	}
+	var key interface{}
+	var err error
+	if block.Type == "RSA PUBLIC KEY" {
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("activitypub: public key is not RSA")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,178 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	deliveryTimeout = 30 * time.Second
+	maxRetryDelay   = time.Hour
+)
+
+// Delivery is one signed POST of an activity to a remote inbox
+type Delivery struct {
+	Inbox string
+	Body  []byte
+	KeyID string
+	Key   *rsa.PrivateKey
+
+	attempts int
+}
+
+// PermanentError marks a delivery the remote server rejected outright,
+// which retrying won't fix
+type PermanentError struct {
+	StatusCode int
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("activitypub: delivery rejected with status %d", e.StatusCode)
+}
+
+// Deliver signs and POSTs d once. 4xx answers other than 429 come back as
+// a *PermanentError.
+func Deliver(ctx context.Context, client *http.Client, d Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if err := Sign(req, d.Body, d.KeyID, d.Key); err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxDocumentSize))
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+		return &PermanentError{StatusCode: res.StatusCode}
+	}
+	return fmt.Errorf("activitypub: delivery to %s failed with status %d", d.Inbox, res.StatusCode)
+}
+
+// Queue delivers activities in the background, retrying failures with
+// exponential backoff. It lives in memory, so pending retries are lost
+// on restart.
+type Queue struct {
+	client      *http.Client
+	retryBase   time.Duration
+	maxAttempts int
+
+	jobs chan Delivery
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	timers map[*time.Timer]struct{}
+}
+
+// NewQueue starts a queue whose n-th retry waits retryBase * 2^(n-1),
+// capped at an hour, giving up after maxAttempts tries
+func NewQueue(client *http.Client, retryBase time.Duration, maxAttempts int) *Queue {
+	q := &Queue{
+		client:      client,
+		retryBase:   retryBase,
+		maxAttempts: maxAttempts,
+		jobs:        make(chan Delivery, 256),
+		quit:        make(chan struct{}),
+		timers:      map[*time.Timer]struct{}{},
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+// Enqueue schedules d for delivery. It doesn't block on slow inboxes.
+func (q *Queue) Enqueue(d Delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.jobs <- d:
+	default:
+		// the worker is backed up, hand the delivery over once it has room
+		q.schedule(d, 0)
+	}
+}
+
+// schedule re-enqueues d after delay, q.mu must be held
+func (q *Queue) schedule(d Delivery, delay time.Duration) {
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		delete(q.timers, t)
+		q.mu.Unlock()
+		select {
+		case q.jobs <- d:
+		case <-q.quit:
+		}
+	})
+	q.timers[t] = struct{}{}
+}
+
+func (q *Queue) retryDelay(attempts int) time.Duration {
+	delay := q.retryBase
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func (q *Queue) run() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.quit:
+			return
+		case d := <-q.jobs:
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			err := Deliver(ctx, q.client, d)
+			cancel()
+			if err == nil {
+				continue
+			}
+			d.attempts++
+			if _, permanent := err.(*PermanentError); permanent || d.attempts >= q.maxAttempts {
+				log.Printf("giving up delivering to %s: %v", d.Inbox, err)
+				continue
+			}
+			q.mu.Lock()
+			if !q.closed {
+				q.schedule(d, q.retryDelay(d.attempts))
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// Close stops the worker and drops pending retries
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	for t := range q.timers {
+		t.Stop()
+	}
+	q.mu.Unlock()
+	close(q.quit)
+	q.wg.Wait()
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Claims are the claims of a Chirpy access token. Scope is a space
+// separated list, as in OAuth 2.0.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+}
+
+func (c *Claims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+// TokenID is the jti, which revocation goes by
+func (c *Claims) TokenID() (uuid.UUID, error) {
+	return uuid.Parse(c.ID)
+}
+
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether the token may be used for scope. Tokens
+// without a scope claim come from a full login and may be used for
+// anything.
+func (c *Claims) HasScope(scope string) bool {
+	scopes := c.Scopes()
+	return len(scopes) == 0 || slices.Contains(scopes, scope)
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaims(t *testing.T) {
+	keys := NewKeySet("testingSecret")
+	userId := uuid.New()
+	token, err := keys.MakeJWT(userId, time.Hour, "chirps:read", "chirps:write")
+	require.NoError(t, err)
+
+	claims, err := keys.ParseJWT(token)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultIssuer, claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{DefaultAudience}, claims.Audience)
+	got, err := claims.UserID()
+	assert.NoError(t, err)
+	assert.Equal(t, userId, got)
+	_, err = claims.TokenID()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"chirps:read", "chirps:write"}, claims.Scopes())
+	assert.True(t, claims.HasScope("chirps:write"))
+	assert.False(t, claims.HasScope("profile:write"))
+
+	// every token gets its own jti
+	other, err := keys.MakeJWT(userId, time.Hour)
+	require.NoError(t, err)
+	otherClaims, err := keys.ParseJWT(other)
+	require.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID)
+	assert.True(t, otherClaims.HasScope("profile:write"))
+}
+
+func TestIssuerAndAudienceEnforced(t *testing.T) {
+	keys := NewKeySet("testingSecret")
+
+	otherAudience := NewKeySet("testingSecret")
+	otherAudience.Audience = "someone-else"
+	token, err := otherAudience.MakeJWT(uuid.New(), time.Hour)
+	require.NoError(t, err)
+	_, err = keys.ParseJWT(token)
+	assert.Error(t, err)
+
+	otherIssuer := NewKeySet("testingSecret")
+	otherIssuer.Issuer = "someone-else"
+	token, err = otherIssuer.MakeJWT(uuid.New(), time.Hour)
+	require.NoError(t, err)
+	_, err = keys.ParseJWT(token)
+	assert.Error(t, err)
+}
+
+func TestLeeway(t *testing.T) {
+	keys := NewKeySet("testingSecret")
+	// expired a second ago
+	token, err := keys.MakeJWT(uuid.New(), -time.Second)
+	require.NoError(t, err)
+	_, err = keys.ParseJWT(token)
+	assert.Error(t, err)
+
+	keys.Leeway = time.Minute
+	_, err = keys.ParseJWT(token)
+	assert.NoError(t, err)
+}
+
+func TestTokensWithoutJtiRejected(t *testing.T) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    DefaultIssuer,
+		Audience:  jwt.ClaimStrings{DefaultAudience},
+		Subject:   uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("testingSecret"))
+	require.NoError(t, err)
+	_, err = NewKeySet("testingSecret").ParseJWT(token)
+	assert.Error(t, err)
+}
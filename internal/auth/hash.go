@@ -2,14 +2,13 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -26,47 +25,23 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
-		Subject:   userID.String(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+// MakeJWT signs an HS256 token with tokenSecret that expires after
+// expiresIn. KeySet also signs with asymmetric keys.
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return NewKeySet(tokenSecret).MakeJWT(userID, expiresIn)
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	// Parse the token with the given secret and claims
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the signing method is HMAC-SHA256
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(tokenSecret), nil
-	})
-
-	if err != nil {
-		return uuid.UUID{}, err
-	}
+	return NewKeySet(tokenSecret).ValidateJWT(tokenString)
+}
 
-	// Extract claims and validate them
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		// Parse user ID from subject
-		userID, err := uuid.Parse(claims.Subject)
-		if err != nil {
-			return uuid.UUID{}, err
-		}
-		return userID, nil
+// GetAPIKey reads an "Authorization: ApiKey <key>" header
+func GetAPIKey(headers http.Header) (string, error) {
+	key, ok := strings.CutPrefix(headers.Get("Authorization"), "ApiKey ")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", errors.New("api key not provided")
 	}
-
-	fmt.Println(tokenString)
-	return uuid.UUID{}, errors.New("invalid token")
+	return strings.TrimSpace(key), nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -80,6 +55,32 @@ func GetBearerToken(headers http.Header) (string, error) {
 
 func MakeRefreshToken() (string, error) {
 	key := make([]byte, 32)
-	rand.Read(key)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
 	return hex.EncodeToString(key), nil
 }
+
+// HashToken returns the SHA-256 digest refresh tokens and personal access
+// tokens are stored and looked up by. Tokens are random, so a fast
+// unsalted hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PersonalAccessTokenPrefix marks personal access tokens, which lets them
+// be told apart from JWTs and spotted by secret scanners
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -43,7 +43,7 @@ func TestJWTSuccess(t *testing.T) {
 	userId := uuid.New()
 	secret := "testingSecret"
 
-	token, err := MakeJWT(userId, secret)
+	token, err := MakeJWT(userId, secret, time.Hour)
 	if assert.NoError(t, err) {
 		assert.NotEmpty(t, token)
 	}
@@ -57,7 +57,7 @@ func TestJWTExpiry(t *testing.T) {
 	userId := uuid.New()
 	secret := "testingSecret"
 
-	token, err := MakeJWT(userId, secret)
+	token, err := MakeJWT(userId, secret, 10*time.Millisecond)
 	if assert.NoError(t, err) {
 		assert.NotEmpty(t, token)
 	}
@@ -72,7 +72,7 @@ func TestIncorrectToken(t *testing.T) {
 	userId := uuid.New()
 	secret := "testingSecret"
 
-	token, err := MakeJWT(userId, secret)
+	token, err := MakeJWT(userId, secret, time.Hour)
 	if assert.NoError(t, err) {
 		assert.NotEmpty(t, token)
 	}
@@ -81,3 +81,31 @@ func TestIncorrectToken(t *testing.T) {
 	_, err = ValidateJWT(token, secret)
 	assert.Error(t, err)
 }
+
+func TestRefreshTokens(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if assert.NoError(t, err) {
+		assert.Len(t, token, 64)
+	}
+	other, err := MakeRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestHashToken(t *testing.T) {
+	// echo -n abc | sha256sum
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", HashToken("abc"))
+	token, err := MakeRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, HashToken(token))
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	assert.NoError(t, err)
+	assert.True(t, IsPersonalAccessToken(token))
+
+	jwt, err := MakeJWT(uuid.New(), "testingSecret", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, IsPersonalAccessToken(jwt))
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	minRSABits      = 2048
+	DefaultIssuer   = "chirpy"
+	DefaultAudience = "chirpy"
+)
+
+// Key is an asymmetric JWT signing key. Its ID is the RFC 7638 thumbprint
+// of the public key and goes into the kid header of the tokens it signs.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// NewKey wraps an Ed25519 key, signing EdDSA, or an RSA key of at least
+// 2048 bits, signing RS256
+func NewKey(private crypto.Signer) (Key, error) {
+	key := Key{private: private}
+	switch k := private.(type) {
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return Key{}, fmt.Errorf("RSA keys need at least %d bits", minRSABits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", private)
+	}
+	key.ID = key.JWK().Thumbprint()
+	return key, nil
+}
+
+// ParsePrivateKeyPEM reads a PKCS #8 Ed25519 or RSA key, or a PKCS #1 RSA
+// key, as written by openssl genpkey
+func ParsePrivateKeyPEM(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block found")
+	}
+	var private any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("unsupported key type %T", private)
+	}
+	return NewKey(signer)
+}
+
+func (k Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// JWK is a public key in JSON Web Key form
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Alg: k.Method.Alg(), Use: "sig"}
+	switch public := k.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	}
+	return jwk
+}
+
+// Thumbprint computes the RFC 7638 thumbprint: the SHA-256 of the key's
+// required members, in lexical order and without whitespace. The members
+// are base64url or fixed names, so none of them need escaping.
+func (j JWK) Thumbprint() string {
+	var canonical string
+	switch j.Kty {
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, j.Crv, j.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet signs and verifies access tokens. The first key signs new tokens
+// and every key verifies, so a new key can be put in front while the old
+// one keeps verifying the tokens it already signed. Without keys, tokens
+// are signed with HS256 and the shared secret. The secret keeps verifying
+// HS256 tokens, which carry no kid, either way.
+type KeySet struct {
+	// Issuer and Audience are set on new tokens and required on the ones
+	// being validated
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew allowed when checking exp, nbf and iat
+	Leeway time.Duration
+	secret []byte
+	keys   []Key
+	byID   map[string]Key
+}
+
+func NewKeySet(secret string, keys ...Key) *KeySet {
+	s := &KeySet{
+		Issuer:   DefaultIssuer,
+		Audience: DefaultAudience,
+		secret:   []byte(secret),
+		keys:     keys,
+		byID:     map[string]Key{},
+	}
+	for _, key := range keys {
+		s.byID[key.ID] = key
+	}
+	return s
+}
+
+// MakeJWT issues an access token for userID that expires after ttl. With
+// scopes the token is limited to them, without any it grants full access.
+func (s *KeySet) MakeJWT(userID uuid.UUID, ttl time.Duration, scopes ...string) (string, error) {
+	now := time.Now().UTC()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Issuer,
+			Audience:  jwt.ClaimStrings{s.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+		Scope: strings.Join(scopes, " "),
+	}
+	if len(s.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	}
+	signing := s.keys[0]
+	token := jwt.NewWithClaims(signing.Method, claims)
+	token.Header["kid"] = signing.ID
+	return token.SignedString(signing.private)
+}
+
+// ParseJWT verifies an access token's signature, issuer, audience and
+// lifetime and returns its claims
+func (s *KeySet) ParseJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey,
+		jwt.WithIssuer(s.Issuer),
+		jwt.WithAudience(s.Audience),
+		jwt.WithLeeway(s.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if _, err := claims.TokenID(); err != nil {
+		return nil, errors.New("token has no valid jti")
+	}
+	return claims, nil
+}
+
+func (s *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims, err := s.ParseJWT(tokenString)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return claims.UserID()
+}
+
+// verificationKey picks the key by kid and insists on that key's
+// algorithm, so a token can't pass off a public key as an HMAC secret
+func (s *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(s.secret) == 0 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return s.secret, nil
+	}
+	key, ok := s.byID[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public(), nil
+}
+
+// JWKS lists the public keys for /.well-known/jwks.json. The HS256 secret
+// is never published.
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEd25519Key(t *testing.T) Key {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := NewKey(private)
+	require.NoError(t, err)
+	return key
+}
+
+func newRSAKey(t *testing.T, bits int) (*rsa.PrivateKey, Key) {
+	private, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	key, err := NewKey(private)
+	require.NoError(t, err)
+	return private, key
+}
+
+func TestKeySetSignsWithKid(t *testing.T) {
+	_, rsaKey := newRSAKey(t, 2048)
+	for _, key := range []Key{newEd25519Key(t), rsaKey} {
+		keys := NewKeySet("", key)
+		userId := uuid.New()
+		token, err := keys.MakeJWT(userId, time.Hour)
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, parsed.Header["kid"])
+		assert.Equal(t, key.Method.Alg(), parsed.Header["alg"])
+
+		got, err := keys.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, got)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t), newEd25519Key(t)
+	userId := uuid.New()
+	oldToken, err := NewKeySet("", oldKey).MakeJWT(userId, time.Hour)
+	require.NoError(t, err)
+
+	// the new key signs while the old one still verifies
+	rotated := NewKeySet("", newKey, oldKey)
+	_, err = rotated.ValidateJWT(oldToken)
+	assert.NoError(t, err)
+	newToken, err := rotated.MakeJWT(userId, time.Hour)
+	require.NoError(t, err)
+	_, err = NewKeySet("", newKey).ValidateJWT(newToken)
+	assert.NoError(t, err)
+
+	// once the old key is dropped its tokens stop working
+	_, err = NewKeySet("", newKey).ValidateJWT(oldToken)
+	assert.Error(t, err)
+}
+
+func TestKeySetKeepsAcceptingHS256(t *testing.T) {
+	userId := uuid.New()
+	token, err := MakeJWT(userId, "testingSecret", time.Hour)
+	require.NoError(t, err)
+
+	got, err := NewKeySet("testingSecret", newEd25519Key(t)).ValidateJWT(token)
+	assert.NoError(t, err)
+	assert.Equal(t, userId, got)
+
+	_, err = NewKeySet("", newEd25519Key(t)).ValidateJWT(token)
+	assert.Error(t, err)
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+	private, key := newRSAKey(t, 2048)
+	keys := NewKeySet("", key)
+
+	// an HS256 token "signed" with the public key, naming the RSA kid
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: uuid.NewString()})
+	forged.Header["kid"] = key.ID
+	token, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	require.NoError(t, err)
+	_, err = keys.ValidateJWT(token)
+	assert.Error(t, err)
+
+	// an unknown kid
+	other, err := NewKeySet("", newEd25519Key(t)).MakeJWT(uuid.New(), time.Hour)
+	require.NoError(t, err)
+	_, err = keys.ValidateJWT(other)
+	assert.Error(t, err)
+}
+
+func TestNewKeyRejectsShortRSA(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = NewKey(private)
+	assert.Error(t, err)
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	require.NoError(t, err)
+	key, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "EdDSA", key.Method.Alg())
+	}
+
+	rsaPrivate, rsaKey := newRSAKey(t, 2048)
+	pkcs1 := x509.MarshalPKCS1PrivateKey(rsaPrivate)
+	key, err = ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pkcs1}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, rsaKey.ID, key.ID)
+		assert.Equal(t, "RS256", key.Method.Alg())
+	}
+
+	_, err = ParsePrivateKeyPEM([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	edKey := newEd25519Key(t)
+	_, rsaKey := newRSAKey(t, 2048)
+	set := NewKeySet("secret", edKey, rsaKey).JWKS()
+	if assert.Len(t, set.Keys, 2) {
+		ed, rsaJWK := set.Keys[0], set.Keys[1]
+		assert.Equal(t, "OKP", ed.Kty)
+		assert.Equal(t, "Ed25519", ed.Crv)
+		assert.Equal(t, edKey.ID, ed.Kid)
+		assert.Equal(t, ed.Kid, ed.Thumbprint())
+		assert.Equal(t, "RSA", rsaJWK.Kty)
+		assert.Equal(t, "AQAB", rsaJWK.E)
+		assert.Equal(t, "RS256", rsaJWK.Alg)
+		assert.Equal(t, rsaKey.ID, rsaJWK.Kid)
+	}
+	assert.Empty(t, NewKeySet("secret").JWKS().Keys)
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 8037 appendix A.3
+	jwk := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", jwk.Thumbprint())
+}
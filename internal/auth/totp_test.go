@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B
+func TestTOTPVectors(t *testing.T) {
+	keys := []struct {
+		name    string
+		newHash func() hash.Hash
+		key     string
+		codes   map[int64]string
+	}{
+		{"SHA1", sha1.New, "12345678901234567890", map[int64]string{
+			59: "94287082", 1111111109: "07081804", 1111111111: "14050471",
+			1234567890: "89005924", 2000000000: "69279037", 20000000000: "65353130",
+		}},
+		{"SHA256", sha256.New, "12345678901234567890123456789012", map[int64]string{
+			59: "46119246", 1111111109: "68084774", 1111111111: "67062674",
+			1234567890: "91819424", 2000000000: "90698825", 20000000000: "77737706",
+		}},
+		{"SHA512", sha512.New, "1234567890123456789012345678901234567890123456789012345678901234", map[int64]string{
+			59: "90693936", 1111111109: "25091201", 1111111111: "99943326",
+			1234567890: "93441116", 2000000000: "38618901", 20000000000: "47863826",
+		}},
+	}
+	for _, k := range keys {
+		for unix, want := range k.codes {
+			step := TOTPStep(time.Unix(unix, 0))
+			assert.Equal(t, want, hotp(k.newHash, []byte(k.key), uint64(step), 8), "%s at %d", k.name, unix)
+		}
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	// the RFC 6238 SHA1 key in base32, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	code, err := TOTPCode(secret, time.Unix(59, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+	code, err = TOTPCode(secret, time.Unix(1111111109, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "081804", code)
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	code, err := TOTPCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := ValidateTOTP(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	// a code from the previous period is still accepted
+	step, ok = ValidateTOTP(secret, code, now.Add(TOTPPeriod))
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	_, ok = ValidateTOTP(secret, code, now.Add(3*TOTPPeriod))
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP("not base32!", code, now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(TOTPURI("Chirpy", "name@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Chirpy:name@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Chirpy", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+	assert.Equal(t, NormalizeRecoveryCode(codes[0]), NormalizeRecoveryCode(" "+codes[0][:5]+codes[0][6:]))
+	assert.Equal(t, "abcdefghij", NormalizeRecoveryCode("ABCDE-FGHIJ"))
+}
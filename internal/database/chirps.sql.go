@@ -97,3 +97,216 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 	)
 	return i, err
 }
+
+const getRecentChirpsByUser = `-- name: GetRecentChirpsByUser :many
+SELECT id, created_at, updated_at, body, user_id FROM chirps
+    WHERE user_id = $1
+    ORDER BY created_at DESC
+    LIMIT $2
+`
+
+type GetRecentChirpsByUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentChirpsByUser(ctx context.Context, arg GetRecentChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsByUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentPublicChirpsByHashtag = `-- name: GetRecentPublicChirpsByHashtag :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE NOT users.is_protected
+        AND EXISTS (
+            SELECT 1 FROM regexp_matches(chirps.body, '#(\w+)', 'g') AS m
+            WHERE lower(m[1]) = lower($1)
+        )
+    ORDER BY chirps.created_at DESC
+    LIMIT $2
+`
+
+type GetRecentPublicChirpsByHashtagParams struct {
+	Tag      string
+	MaxItems int32
+}
+
+func (q *Queries) GetRecentPublicChirpsByHashtag(ctx context.Context, arg GetRecentPublicChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPublicChirpsByHashtag, arg.Tag, arg.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentVisibleChirps = `-- name: GetRecentVisibleChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE NOT users.is_protected
+        OR chirps.user_id = $1
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $1
+                AND follows.followee_id = chirps.user_id
+                AND follows.status = 'approved'
+        )
+    ORDER BY chirps.created_at DESC
+    LIMIT $2
+`
+
+type GetRecentVisibleChirpsParams struct {
+	ViewerID uuid.UUID
+	MaxItems int32
+}
+
+func (q *Queries) GetRecentVisibleChirps(ctx context.Context, arg GetRecentVisibleChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentVisibleChirps, arg.ViewerID, arg.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirpById = `-- name: GetVisibleChirpById :one
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE chirps.id = $1
+        AND (
+            NOT users.is_protected
+            OR chirps.user_id = $2
+            OR EXISTS (
+                SELECT 1 FROM follows
+                WHERE follows.follower_id = $2
+                    AND follows.followee_id = chirps.user_id
+                    AND follows.status = 'approved'
+            )
+        )
+`
+
+type GetVisibleChirpByIdParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirpById(ctx context.Context, arg GetVisibleChirpByIdParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirpById, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getVisibleChirps = `-- name: GetVisibleChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE NOT users.is_protected
+        OR chirps.user_id = $1
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $1
+                AND follows.followee_id = chirps.user_id
+                AND follows.status = 'approved'
+        )
+    ORDER BY chirps.created_at
+`
+
+func (q *Queries) GetVisibleChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getVisibleChirps, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -11,6 +11,12 @@ import (
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -19,20 +25,248 @@ type Chirp struct {
 	UserID    uuid.UUID
 }
 
-type RefreshToken struct {
-	Token     string
+type ChirpImpression struct {
+	ChirpID     uuid.UUID
+	Hour        time.Time
+	Impressions int64
+}
+
+type ChirpLinkPreview struct {
+	ChirpID uuid.UUID
+	Url     string
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DirectKey sql.NullString
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadSeq    int64
+	JoinedAt       time.Time
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type LinkPreview struct {
+	Url         string
+	Ok          bool
+	Title       string
+	Description string
+	ImageUrl    string
+	SiteName    string
+	FetchedAt   time.Time
+}
+
+type List struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	IsPrivate bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type LoginChallenge struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Attempts  int32
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type MagicLink struct {
+	TokenHash string
+	UserID    uuid.UUID
+	NonceHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	Email     string
+}
+
+type MagicLinkRequest struct {
+	Email     string
+	CreatedAt time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	Seq            int64
+	CreatedAt      time.Time
+}
+
+type Notification struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Type       string
+	ActorID    uuid.NullUUID
+	ChirpID    uuid.NullUUID
+	ActorCount int32
+	Seq        int64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReadAt     sql.NullTime
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type RecoveryCode struct {
+	UserID   uuid.UUID
+	CodeHash string
+	UsedAt   sql.NullTime
+}
+
+type RefreshToken struct {
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	UserID     uuid.UUID
+	FamilyID   uuid.UUID
+	ParentHash sql.NullString
+	RotatedAt  sql.NullTime
+}
+
+type RemoteFollow struct {
+	UserID    uuid.UUID
+	ActorID   string
+	Inbox     string
+	Accepted  bool
+	CreatedAt time.Time
+}
+
+type RemoteFollower struct {
+	UserID    uuid.UUID
+	ActorID   string
+	Inbox     string
+	CreatedAt time.Time
+}
+
+type RevokedAccessToken struct {
+	Jti       uuid.UUID
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	UserAgent  string
+	Ip         string
+}
+
+type Suspension struct {
 	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type TotpSecret struct {
+	UserID         uuid.UUID
+	Secret         string
+	ConfirmedAt    sql.NullTime
+	LastStep       int64
+	CreatedAt      time.Time
+	FailedAttempts int32
+	LockedUntil    sql.NullTime
 }
 
 type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	IsProtected     bool
+	EmailVerifiedAt sql.NullTime
+}
+
+type UserKey struct {
+	UserID        uuid.UUID
+	PublicKeyPem  string
+	PrivateKeyPem string
+	CreatedAt     time.Time
+}
+
+type UserSuggestion struct {
+	UserID         uuid.UUID
+	SuggestedID    uuid.UUID
+	Score          int32
+	MutualFollows  int32
+	SharedHashtags int32
+	RecentChirps   int32
+	ComputedAt     time.Time
+}
+
+type Webhook struct {
+	ID                  uuid.UUID
+	UserID              uuid.UUID
+	Url                 string
+	Secret              string
+	Events              []string
+	Active              bool
+	ConsecutiveFailures int32
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int32
+	ResponseStatus sql.NullInt32
+	Error          sql.NullString
+	NextAttemptAt  time.Time
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
 }
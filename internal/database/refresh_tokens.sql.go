@@ -14,69 +14,116 @@ import (
 )
 
 const createRefereshToken = `-- name: CreateRefereshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, expires_at, user_id)
+INSERT INTO refresh_tokens(token_hash, created_at, updated_at, expires_at, user_id, family_id, parent_hash)
 VALUES (
-    $1, $2, $3, $4, $5
-) RETURNING token, created_at, updated_at, expires_at, revoked_at, user_id
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING token_hash, created_at, updated_at, expires_at, revoked_at, user_id, family_id, parent_hash, rotated_at
 `
 
 type CreateRefereshTokenParams struct {
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	ExpiresAt time.Time
-	UserID    uuid.UUID
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ExpiresAt  time.Time
+	UserID     uuid.UUID
+	FamilyID   uuid.UUID
+	ParentHash sql.NullString
 }
 
 func (q *Queries) CreateRefereshToken(ctx context.Context, arg CreateRefereshTokenParams) (RefreshToken, error) {
 	row := q.db.QueryRowContext(ctx, createRefereshToken,
-		arg.Token,
+		arg.TokenHash,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.ExpiresAt,
 		arg.UserID,
+		arg.FamilyID,
+		arg.ParentHash,
 	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.ExpiresAt,
 		&i.RevokedAt,
 		&i.UserID,
+		&i.FamilyID,
+		&i.ParentHash,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT token, created_at, updated_at, expires_at, revoked_at, user_id FROM refresh_tokens WHERE token = $1
+SELECT token_hash, created_at, updated_at, expires_at, revoked_at, user_id, family_id, parent_hash, rotated_at FROM refresh_tokens WHERE token_hash = $1
 `
 
-func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, token)
+func (q *Queries) GetUserFromRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.ExpiresAt,
 		&i.RevokedAt,
 		&i.UserID,
+		&i.FamilyID,
+		&i.ParentHash,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+    SET revoked_at = $1, updated_at = $1
+    WHERE family_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokenFamilyParams struct {
+	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+}
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, arg RevokeRefreshTokenFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, arg.RevokedAt, arg.FamilyID)
+	return err
+}
+
 const revokeToken = `-- name: RevokeToken :exec
 UPDATE refresh_tokens
-    SET revoked_at = $1 WHERE token = $2
+    SET revoked_at = $1, updated_at = $1
+    WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $2)
+        AND revoked_at IS NULL
 `
 
 type RevokeTokenParams struct {
 	RevokedAt sql.NullTime
-	Token     string
+	TokenHash string
 }
 
+// revokes every token in the family of the given token
 func (q *Queries) RevokeToken(ctx context.Context, arg RevokeTokenParams) error {
-	_, err := q.db.ExecContext(ctx, revokeToken, arg.RevokedAt, arg.Token)
+	_, err := q.db.ExecContext(ctx, revokeToken, arg.RevokedAt, arg.TokenHash)
 	return err
 }
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execresult
+UPDATE refresh_tokens
+    SET rotated_at = $1, updated_at = $1
+    WHERE token_hash = $2 AND rotated_at IS NULL AND revoked_at IS NULL
+`
+
+type RotateRefreshTokenParams struct {
+	RotatedAt sql.NullTime
+	TokenHash string
+}
+
+// marks a token as used up. It affects no rows when the token was already
+// rotated or revoked, which makes concurrent refreshes with the same token
+// count as reuse.
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, rotateRefreshToken, arg.RotatedAt, arg.TokenHash)
+}
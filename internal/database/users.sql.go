@@ -17,7 +17,7 @@ const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES(
     gen_random_uuid(), NOW(), NOW(), $1, $2
-) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at
 `
 
 type CreateUserParams struct {
@@ -35,6 +35,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -49,7 +51,7 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -62,25 +64,94 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
+const getUserById = `-- name: GetUserById :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :execresult
+UPDATE users
+    SET email_verified_at=NOW(), updated_at=NOW()
+    WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+// only verifies the address the token was sent to, in case the email was
+// changed since
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, markEmailVerified, arg.ID, arg.Email)
+}
+
 const markUserRed = `-- name: MarkUserRed :execresult
 UPDATE users
     SET is_chirpy_red=true
-    WHERE id = $1
+    WHERE id = $1 AND NOT is_chirpy_red
 `
 
+// changes no row if the user doesn't exist or is already Red
 func (q *Queries) MarkUserRed(ctx context.Context, id uuid.UUID) (sql.Result, error) {
 	return q.db.ExecContext(ctx, markUserRed, id)
 }
 
+const setUserProtected = `-- name: SetUserProtected :one
+UPDATE users
+    SET is_protected=$1, updated_at=NOW()
+    WHERE id = $2
+    RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at
+`
+
+type SetUserProtectedParams struct {
+	IsProtected bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserProtected(ctx context.Context, arg SetUserProtectedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserProtected, arg.IsProtected, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
 const updateUserById = `-- name: UpdateUserById :one
 UPDATE users
-    SET updated_at=$1, email=$2, hashed_password=$3
+    SET updated_at=$1, email=$2, hashed_password=$3,
+        email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
     WHERE id = $4
-    RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+    RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at
 `
 
 type UpdateUserByIdParams struct {
@@ -90,6 +161,7 @@ type UpdateUserByIdParams struct {
 	ID             uuid.UUID
 }
 
+// changing the email clears its verification
 func (q *Queries) UpdateUserById(ctx context.Context, arg UpdateUserByIdParams) (User, error) {
 	row := q.db.QueryRowContext(ctx, updateUserById,
 		arg.UpdatedAt,
@@ -105,6 +177,24 @@ func (q *Queries) UpdateUserById(ctx context.Context, arg UpdateUserByIdParams)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+    SET hashed_password=$1, updated_at=NOW()
+    WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
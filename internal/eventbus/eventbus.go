@@ -0,0 +1,85 @@
+// Package eventbus carries domain events between handlers and, with the
+// Postgres implementation, between Chirpy instances
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// event types
+const (
+	ChirpCreated        = "chirp.created"
+	ChirpDeleted        = "chirp.deleted"
+	FollowCreated       = "follow.created"
+	UserUpgraded        = "user.upgraded"
+	NotificationCreated = "notification.created"
+)
+
+type Event struct {
+	// ID is assigned once when the event is created, so every instance
+	// knows the event by the same ID
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// NewEvent marshals data into an Event of the given type
+func NewEvent(typ string, data interface{}) (Event, error) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: uuid.NewString(), Type: typ, Data: dat}, nil
+}
+
+// Handler receives delivered events. Handlers are called one at a time
+// from the bus's delivery goroutine and must not block.
+type Handler func(Event)
+
+type Bus interface {
+	// Publish sends the event to every subscriber, on every instance
+	// sharing the bus
+	Publish(ctx context.Context, event Event) error
+	// Subscribe registers handler and returns a function removing it
+	Subscribe(handler Handler) (unsubscribe func())
+	Close() error
+}
+
+// subscribers is the local fan out shared by the implementations
+type subscribers struct {
+	mu       sync.Mutex
+	nextID   int
+	handlers map[int]Handler
+}
+
+func (s *subscribers) Subscribe(handler Handler) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = map[int]Handler{}
+	}
+	id := s.nextID
+	s.nextID++
+	s.handlers[id] = handler
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.handlers, id)
+	}
+}
+
+func (s *subscribers) dispatch(event Event) {
+	s.mu.Lock()
+	handlers := make([]Handler, 0, len(s.handlers))
+	for _, handler := range s.handlers {
+		handlers = append(handlers, handler)
+	}
+	s.mu.Unlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
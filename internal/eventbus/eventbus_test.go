@@ -0,0 +1,69 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBusDelivers(t *testing.T) {
+	bus := NewMemoryBus()
+	received := []Event{}
+	unsubscribe := bus.Subscribe(func(e Event) {
+		received = append(received, e)
+	})
+
+	event, err := NewEvent(ChirpCreated, map[string]string{"id": "1"})
+	assert.NoError(t, err)
+	assert.NoError(t, bus.Publish(context.Background(), event))
+
+	unsubscribe()
+	assert.NoError(t, bus.Publish(context.Background(), event))
+
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, ChirpCreated, received[0].Type)
+		assert.JSONEq(t, `{"id":"1"}`, string(received[0].Data))
+	}
+}
+
+// TestPostgresBusRoundTrip needs a database, set CHIRPY_TEST_DB_URL to run it
+func TestPostgresBusRoundTrip(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	publisher, err := NewPostgresBus(db, dbURL, "chirpy_events_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	receiver, err := NewPostgresBus(db, dbURL, "chirpy_events_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	received := make(chan Event, 1)
+	receiver.Subscribe(func(e Event) { received <- e })
+
+	event, _ := NewEvent(UserUpgraded, map[string]string{"user_id": "u"})
+	assert.NoError(t, publisher.Publish(context.Background(), event))
+
+	select {
+	case e := <-received:
+		assert.Equal(t, UserUpgraded, e.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+}
@@ -0,0 +1,28 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBus delivers events within a single process
+type MemoryBus struct {
+	subscribers
+	// deliverMu keeps deliveries in publish order
+	deliverMu sync.Mutex
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, event Event) error {
+	b.deliverMu.Lock()
+	defer b.deliverMu.Unlock()
+	b.dispatch(event)
+	return nil
+}
+
+func (b *MemoryBus) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxNotifyPayload is just under Postgres' 8000 byte NOTIFY payload limit
+const maxNotifyPayload = 7999
+
+// PostgresBus publishes with NOTIFY and receives with LISTEN, so every
+// instance connected to the same database sees every event, its own
+// included
+type PostgresBus struct {
+	subscribers
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+	done     chan struct{}
+}
+
+func NewPostgresBus(db *sql.DB, connStr, channel string) (*PostgresBus, error) {
+	listener := pq.NewListener(connStr, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event bus listener: %v", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	b := &PostgresBus{
+		db:       db,
+		listener: listener,
+		channel:  channel,
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *PostgresBus) run() {
+	defer close(b.done)
+	for {
+		select {
+		case n, open := <-b.listener.Notify:
+			if !open {
+				return
+			}
+			// a nil notification means the connection was re-established
+			// and anything sent in between is lost
+			if n == nil {
+				log.Printf("event bus listener reconnected, events may have been missed")
+				continue
+			}
+			event := Event{}
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				log.Printf("event bus: dropping malformed event: %v", err)
+				continue
+			}
+			b.dispatch(event)
+		case <-time.After(90 * time.Second):
+			go b.listener.Ping()
+		}
+	}
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		return errors.New("eventbus: event too large for NOTIFY")
+	}
+	_, err = b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload))
+	return err
+}
+
+func (b *PostgresBus) Close() error {
+	err := b.listener.Close()
+	<-b.done
+	return err
+}
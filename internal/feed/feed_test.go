@@ -0,0 +1,72 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testFeed() Feed {
+	published := time.Date(2025, 2, 5, 14, 42, 41, 0, time.UTC)
+	return Feed{
+		ID:      "urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		Title:   "Chirps by someone",
+		Link:    "http://localhost:8080/app/",
+		Self:    "http://localhost:8080/feeds/users/someone.atom",
+		Updated: published,
+		Entries: []Entry{{
+			ID:        "urn:uuid:6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+			Link:      "http://localhost:8080/api/chirps/6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+			Title:     "<b>bold</b> & co",
+			Content:   "<script>alert(1)</script> & co",
+			Author:    "someone",
+			Published: published,
+			Updated:   published,
+		}},
+	}
+}
+
+func TestAtomEscapesAndParses(t *testing.T) {
+	dat, err := testFeed().Atom()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(dat), "<script>")
+	assert.Contains(t, string(dat), "&lt;script&gt;")
+
+	parsed := struct {
+		ID      string `xml:"id"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}{}
+	assert.NoError(t, xml.Unmarshal(dat, &parsed))
+	assert.Equal(t, "urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8", parsed.ID)
+	assert.Equal(t, "2025-02-05T14:42:41Z", parsed.Updated)
+	if assert.Len(t, parsed.Entries, 1) {
+		assert.Equal(t, "<script>alert(1)</script> & co", parsed.Entries[0].Content)
+	}
+}
+
+func TestRSSParses(t *testing.T) {
+	dat, err := testFeed().RSS()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(dat), "<?xml"))
+
+	parsed := struct {
+		Version string `xml:"version,attr"`
+		Items   []struct {
+			GUID    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"channel>item"`
+	}{}
+	assert.NoError(t, xml.Unmarshal(dat, &parsed))
+	assert.Equal(t, "2.0", parsed.Version)
+	if assert.Len(t, parsed.Items, 1) {
+		assert.Equal(t, "urn:uuid:6ba7b811-9dad-11d1-80b4-00c04fd430c8", parsed.Items[0].GUID)
+		assert.Equal(t, "Wed, 05 Feb 2025 14:42:41 +0000", parsed.Items[0].PubDate)
+	}
+}
@@ -0,0 +1,122 @@
+// Package impressions counts chirp views in memory and hands them to the
+// database in hourly batches, so serving chirps never waits on a write
+package impressions
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch is the impressions counted for a set of chirps during one hour
+type Batch struct {
+	Hour     time.Time
+	ChirpIDs []uuid.UUID
+	Counts   []int64
+}
+
+// FlushFunc stores a batch, adding it to whatever was stored before
+type FlushFunc func(ctx context.Context, batch Batch) error
+
+type key struct {
+	chirpID uuid.UUID
+	hour    time.Time
+}
+
+type Recorder struct {
+	flush      FlushFunc
+	maxPending int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	pending map[key]int64
+	// full wakes Run for an early flush once maxPending chirps are waiting
+	full chan struct{}
+}
+
+// NewRecorder returns a recorder flushing through flush. Once maxPending
+// distinct chirp-hours are waiting Run flushes without waiting for its
+// interval.
+func NewRecorder(flush FlushFunc, maxPending int) *Recorder {
+	return &Recorder{
+		flush:      flush,
+		maxPending: maxPending,
+		now:        time.Now,
+		pending:    map[key]int64{},
+		full:       make(chan struct{}, 1),
+	}
+}
+
+// Record counts one impression for each chirp
+func (r *Recorder) Record(chirpIDs ...uuid.UUID) {
+	if len(chirpIDs) == 0 {
+		return
+	}
+	hour := r.now().UTC().Truncate(time.Hour)
+	r.mu.Lock()
+	for _, id := range chirpIDs {
+		r.pending[key{chirpID: id, hour: hour}]++
+	}
+	full := len(r.pending) >= r.maxPending
+	r.mu.Unlock()
+	if full {
+		select {
+		case r.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush hands everything counted so far to the FlushFunc, one batch per
+// hour. Counts of a batch that fails to store are dropped.
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = map[key]int64{}
+	r.mu.Unlock()
+
+	batches := map[time.Time]*Batch{}
+	for k, count := range pending {
+		batch, ok := batches[k.hour]
+		if !ok {
+			batch = &Batch{Hour: k.hour}
+			batches[k.hour] = batch
+		}
+		batch.ChirpIDs = append(batch.ChirpIDs, k.chirpID)
+		batch.Counts = append(batch.Counts, count)
+	}
+	var firstErr error
+	for _, batch := range batches {
+		if err := r.flush(ctx, *batch); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Run flushes every interval, or sooner when the buffer fills up, until
+// ctx is done, then flushes one last time
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is already cancelled, so the final flush gets its own
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := r.Flush(flushCtx); err != nil {
+				log.Printf("error flushing impressions: %v", err)
+			}
+			return
+		case <-ticker.C:
+		case <-r.full:
+		}
+		if err := r.Flush(ctx); err != nil {
+			log.Printf("error flushing impressions: %v", err)
+		}
+	}
+}
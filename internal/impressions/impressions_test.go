@@ -0,0 +1,87 @@
+package impressions
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type store struct {
+	mu      sync.Mutex
+	batches []Batch
+	flushed chan struct{}
+}
+
+func (s *store) flush(ctx context.Context, batch Batch) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, batch)
+	s.mu.Unlock()
+	select {
+	case s.flushed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *store) total(hour time.Time, id uuid.UUID) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total int64
+	for _, batch := range s.batches {
+		for i, chirpID := range batch.ChirpIDs {
+			if batch.Hour.Equal(hour) && chirpID == id {
+				total += batch.Counts[i]
+			}
+		}
+	}
+	return total
+}
+
+func TestFlushGroupsByHour(t *testing.T) {
+	s := &store{flushed: make(chan struct{}, 1)}
+	r := NewRecorder(s.flush, 1000)
+	a, b := uuid.New(), uuid.New()
+	first := time.Date(2025, 2, 5, 14, 42, 0, 0, time.UTC)
+	r.now = func() time.Time { return first }
+	r.Record(a, b, a)
+	r.now = func() time.Time { return first.Add(time.Hour) }
+	r.Record(a)
+
+	assert.NoError(t, r.Flush(context.Background()))
+	assert.Len(t, s.batches, 2)
+	assert.Equal(t, int64(2), s.total(first.Truncate(time.Hour), a))
+	assert.Equal(t, int64(1), s.total(first.Truncate(time.Hour), b))
+	assert.Equal(t, int64(1), s.total(first.Add(time.Hour).Truncate(time.Hour), a))
+
+	// a second flush has nothing left to send
+	assert.NoError(t, r.Flush(context.Background()))
+	assert.Len(t, s.batches, 2)
+}
+
+func TestRunFlushesEarlyWhenFull(t *testing.T) {
+	s := &store{flushed: make(chan struct{}, 1)}
+	r := NewRecorder(s.flush, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	r.Record(uuid.New(), uuid.New())
+	select {
+	case <-s.flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("full buffer was not flushed")
+	}
+
+	id := uuid.New()
+	r.Record(id)
+	cancel()
+	<-done
+	assert.Equal(t, int64(1), s.total(time.Now().UTC().Truncate(time.Hour), id), "flushed on shutdown")
+}
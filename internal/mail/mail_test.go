@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := Format("chirpy@example.com", Message{
+		To:      "name@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two\n",
+	}, date)
+	require.NoError(t, err)
+	text := string(data)
+	assert.Contains(t, text, "From: chirpy@example.com\r\n")
+	assert.Contains(t, text, "To: name@example.com\r\n")
+	assert.Contains(t, text, "Subject: Reset your password\r\n")
+	assert.Contains(t, text, "Date: Thu, 02 Jan 2025 03:04:05 +0000\r\n")
+	assert.True(t, strings.HasSuffix(text, "\r\n\r\nline one\r\nline two\r\n"))
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	_, err := Format("chirpy@example.com", Message{
+		To:      "name@example.com\r\nBcc: everyone@example.com",
+		Subject: "hi",
+	}, time.Now())
+	assert.Error(t, err)
+	_, err = Format("chirpy@example.com", Message{
+		To:      "name@example.com",
+		Subject: "hi\nBcc: everyone@example.com",
+	}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	msg := Message{To: "name@example.com", Subject: "hi", Body: "hello"}
+	require.NoError(t, m.Send(context.Background(), msg))
+	assert.Equal(t, []Message{msg}, m.Messages())
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "chirpy@example.com")
+	require.NoError(t, err)
+	require.NoError(t, m.Send(context.Background(), Message{To: "name@example.com", Subject: "hi", Body: "hello"}))
+	require.NoError(t, m.Send(context.Background(), Message{To: "other@example.com", Subject: "hi", Body: "hello"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: hi\r\n")
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
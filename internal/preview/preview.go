@@ -0,0 +1,253 @@
+// Package preview fetches OpenGraph and Twitter card metadata for links
+// in chirps. Fetches are made on behalf of arbitrary users, so the client
+// refuses to connect to loopback, private and other internal addresses.
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	fetchTimeout    = 5 * time.Second
+	maxBodySize     = 512 << 10
+	maxRedirects    = 3
+	maxTitleRunes   = 200
+	maxDescRunes    = 500
+	maxURLLength    = 2048
+	userAgent       = "ChirpyBot/1.0 (link previews)"
+	acceptedContent = "text/html"
+)
+
+var (
+	urlPattern       = regexp.MustCompile(`https?://[^\s<>"']+`)
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern      = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	titleTagPattern  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	ErrBlockedTarget = errors.New("preview: destination address is not allowed")
+)
+
+// blockedPrefixes are ranges netip's Is* helpers don't already cover
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// FirstURL returns the first http or https link in a chirp body
+func FirstURL(body string) string {
+	for _, match := range urlPattern.FindAllString(body, -1) {
+		link := strings.TrimRight(match, ".,;:!?)]}")
+		if len(link) > maxURLLength {
+			continue
+		}
+		if u, err := url.Parse(link); err == nil && u.Host != "" {
+			return link
+		}
+	}
+	return ""
+}
+
+// PublicAddr reports whether addr is safe to fetch from, meaning it is
+// a globally routable unicast address
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+type Fetcher struct {
+	client *http.Client
+	// slots bounds how many fetches run at once
+	slots chan struct{}
+	// allowAddr decides which resolved addresses may be dialled
+	allowAddr func(netip.Addr) bool
+}
+
+// NewFetcher returns a fetcher that only connects to public addresses,
+// running at most maxConcurrent fetches at a time. The address check runs
+// on the resolved address at dial time, so DNS answers and redirects
+// pointing inside the network are refused too.
+func NewFetcher(maxConcurrent int) *Fetcher {
+	f := &Fetcher{
+		allowAddr: PublicAddr,
+		slots:     make(chan struct{}, maxConcurrent),
+	}
+	f.client = newClient(fetchTimeout, func(addr netip.Addr) bool {
+		return f.allowAddr(addr)
+	})
+	return f
+}
+
+// NewPublicClient returns a client for other requests to URLs users or
+// remote servers choose. Like the fetcher's it only connects to public
+// addresses and follows at most a few redirects; callers still have to
+// cap how much of a response they read.
+func NewPublicClient(timeout time.Duration) *http.Client {
+	return newClient(timeout, PublicAddr)
+}
+
+func newClient(timeout time.Duration, allowAddr func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowAddr(addrPort.Addr()) {
+				return ErrBlockedTarget
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("preview: too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("preview: redirect to unsupported scheme")
+			}
+			return nil
+		},
+	}
+}
+
+// Fetch loads link and reads its preview metadata. Pages without a title
+// are reported as an error.
+func (f *Fetcher) Fetch(ctx context.Context, link string) (Preview, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Preview{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Preview{}, fmt.Errorf("preview: unsupported scheme %q", u.Scheme)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	select {
+	case f.slots <- struct{}{}:
+		defer func() { <-f.slots }()
+	case <-ctx.Done():
+		return Preview{}, ctx.Err()
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", acceptedContent)
+	res, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("preview: %s answered %d", link, res.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType != acceptedContent {
+		return Preview{}, fmt.Errorf("preview: %s is not HTML", link)
+	}
+	dat, err := io.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	if err != nil {
+		return Preview{}, err
+	}
+	p := Parse(string(dat), res.Request.URL)
+	p.URL = link
+	if p.Title == "" {
+		return Preview{}, fmt.Errorf("preview: %s has no title", link)
+	}
+	return p, nil
+}
+
+// Parse reads OpenGraph and Twitter card meta tags from a page, falling
+// back to <title> and the description meta tag. Relative image URLs are
+// resolved against base.
+func Parse(page string, base *url.URL) Preview {
+	meta := map[string]string{}
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		attrs := map[string]string{}
+		for _, attr := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(attr[1])] = html.UnescapeString(strings.Trim(attr[2], `"'`))
+		}
+		name := attrs["property"]
+		if name == "" {
+			name = attrs["name"]
+		}
+		name = strings.ToLower(name)
+		if _, seen := meta[name]; name != "" && !seen {
+			meta[name] = strings.TrimSpace(attrs["content"])
+		}
+	}
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := meta[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	p := Preview{
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		SiteName:    first("og:site_name"),
+	}
+	if p.Title == "" {
+		if m := titleTagPattern.FindStringSubmatch(page); m != nil {
+			p.Title = strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+		}
+	}
+	p.Title = truncate(p.Title, maxTitleRunes)
+	p.Description = truncate(p.Description, maxDescRunes)
+	p.SiteName = truncate(p.SiteName, maxTitleRunes)
+	if image := first("og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if imageURL, err := base.Parse(image); err == nil && (imageURL.Scheme == "http" || imageURL.Scheme == "https") {
+			p.ImageURL = imageURL.String()
+		}
+	}
+	return p
+}
+
+func truncate(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes]) + "…"
+}
@@ -0,0 +1,126 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/a?b=c", FirstURL("look at https://example.com/a?b=c."))
+	assert.Equal(t, "http://example.com", FirstURL("(http://example.com) and https://other.example"))
+	assert.Equal(t, "", FirstURL("no links, ftp://example.com or https:// here"))
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:2800:220::1": true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		assert.Equal(t, public, PublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+	p := Parse(`<html><head>
+		<title>Fallback</title>
+		<meta property="og:title" content="Hello &amp; welcome">
+		<meta name='twitter:description' content='From twitter'>
+		<meta content="/img/card.png" property="og:image" />
+		<meta property="og:site_name" content="Example">
+	</head></html>`, base)
+	assert.Equal(t, "Hello & welcome", p.Title)
+	assert.Equal(t, "From twitter", p.Description)
+	assert.Equal(t, "https://example.com/img/card.png", p.ImageURL)
+	assert.Equal(t, "Example", p.SiteName)
+
+	p = Parse(`<title>
+		Only   a title </title><meta property="og:image" content="javascript:alert(1)">`, base)
+	assert.Equal(t, "Only a title", p.Title)
+	assert.Equal(t, "", p.ImageURL)
+}
+
+// testFetcher is a fetcher allowed to reach the loopback httptest server
+func testFetcher() *Fetcher {
+	f := NewFetcher(2)
+	f.allowAddr = func(addr netip.Addr) bool { return addr.IsLoopback() }
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<meta property="og:title" content="A page"><meta property="og:image" content="/i.png">`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(strings.Repeat(" ", maxBodySize) + `<title>too late</title>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, err := testFetcher().Fetch(context.Background(), srv.URL+"/moved")
+	assert.NoError(t, err)
+	assert.Equal(t, "A page", p.Title)
+	assert.Equal(t, srv.URL+"/i.png", p.ImageURL)
+	assert.Equal(t, srv.URL+"/moved", p.URL)
+
+	_, err = testFetcher().Fetch(context.Background(), srv.URL+"/json")
+	assert.Error(t, err)
+	_, err = testFetcher().Fetch(context.Background(), srv.URL+"/huge")
+	assert.Error(t, err)
+	_, err = testFetcher().Fetch(context.Background(), srv.URL+"/missing")
+	assert.Error(t, err)
+}
+
+func TestFetchBlocksInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the internal server")
+	}))
+	defer srv.Close()
+
+	_, err := NewFetcher(2).Fetch(context.Background(), srv.URL)
+	assert.True(t, errors.Is(err, ErrBlockedTarget), err)
+
+	localhost := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	_, err = NewFetcher(2).Fetch(context.Background(), localhost)
+	assert.True(t, errors.Is(err, ErrBlockedTarget), err)
+}
+
+func TestPublicClientBlocksInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the internal server")
+	}))
+	defer srv.Close()
+
+	_, err := NewPublicClient(time.Second).Post(srv.URL, "application/json", strings.NewReader("{}"))
+	assert.True(t, errors.Is(err, ErrBlockedTarget), err)
+}
@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// event types sent to stream subscribers
+const (
+	EventChirp        = "chirp"
+	EventTombstone    = "tombstone"
+	EventNotification = "notification"
+)
+
+type Event struct {
+	// ID is the event bus ID, the same on every instance
+	ID       string
+	Type     string
+	AuthorID uuid.UUID
+	// AuthorProtected marks events that may only reach the author's
+	// approved followers
+	AuthorProtected bool
+	Hashtags        []string
+	// RecipientID is only set on notification events, which go to that
+	// user alone
+	RecipientID uuid.UUID
+	Data        []byte
+}
+
+// Subscriber receives events on C. C is closed when the subscriber falls
+// too far behind, after which Dropped reports true.
+type Subscriber struct {
+	C       <-chan Event
+	ch      chan Event
+	dropped atomic.Bool
+}
+
+func (s *Subscriber) Dropped() bool {
+	return s.dropped.Load()
+}
+
+// Hub fans published events out to subscribers and keeps a short history
+// so reconnecting clients can resume from their Last-Event-ID. Every
+// instance receives bus events in the same order, so a client can resume
+// on a different instance than the one it was connected to.
+type Hub struct {
+	mu         sync.Mutex
+	history    []Event
+	historyCap int
+	bufferSize int
+	subs       map[*Subscriber]struct{}
+	closed     bool
+}
+
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		historyCap: historySize,
+		bufferSize: bufferSize,
+		subs:       map[*Subscriber]struct{}{},
+	}
+}
+
+// Publish hands the event to every subscriber. Subscribers whose buffer is
+// full are dropped rather than waited on, so one slow client can never
+// stall the publisher.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.history = append(h.history, event)
+	if len(h.history) > h.historyCap {
+		h.history = h.history[len(h.history)-h.historyCap:]
+	}
+	for sub := range h.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. Events after lastEventID that are
+// still in the history are returned as a backlog to send before reading
+// from C; nothing is lost or repeated between the two. If lastEventID is
+// no longer in the history the whole history is returned.
+func (h *Hub) Subscribe(lastEventID string) (*Subscriber, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan Event, h.bufferSize)
+	sub := &Subscriber{C: ch, ch: ch}
+	if h.closed {
+		close(ch)
+		return sub, []Event{}
+	}
+	h.subs[sub] = struct{}{}
+
+	backlog := []Event{}
+	if lastEventID == "" {
+		return sub, backlog
+	}
+	start := 0
+	for i, event := range h.history {
+		if event.ID == lastEventID {
+			start = i + 1
+			break
+		}
+	}
+	return sub, append(backlog, h.history[start:]...)
+}
+
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Close ends every subscription, closing C, and any made afterwards, so
+// long-lived streams finish when the server shuts down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// drop must be called with h.mu held
+func (h *Hub) drop(sub *Subscriber) {
+	sub.dropped.Store(true)
+	delete(h.subs, sub)
+	close(sub.ch)
+}
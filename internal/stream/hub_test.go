@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishReachesSubscriber(t *testing.T) {
+	hub := NewHub(10, 10)
+	sub, backlog := hub.Subscribe("")
+	assert.Empty(t, backlog)
+
+	author := uuid.New()
+	hub.Publish(Event{ID: "a", Type: EventChirp, AuthorID: author, Data: []byte("{}")})
+
+	event := <-sub.C
+	assert.Equal(t, "a", event.ID)
+	assert.Equal(t, EventChirp, event.Type)
+	assert.Equal(t, author, event.AuthorID)
+}
+
+func TestSubscribeResumesFromLastEventID(t *testing.T) {
+	hub := NewHub(3, 10)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		hub.Publish(Event{ID: id, Type: EventChirp, AuthorID: uuid.New()})
+	}
+
+	_, backlog := hub.Subscribe("b")
+	if assert.Len(t, backlog, 2) {
+		assert.Equal(t, "c", backlog[0].ID)
+		assert.Equal(t, "d", backlog[1].ID)
+	}
+
+	_, backlog = hub.Subscribe("d")
+	assert.Empty(t, backlog)
+
+	// only the last three events are kept, so a client that missed more
+	// gets all of them
+	_, backlog = hub.Subscribe("a")
+	assert.Len(t, backlog, 3)
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(10, 1)
+	slow, _ := hub.Subscribe("")
+	fast, _ := hub.Subscribe("")
+
+	hub.Publish(Event{Type: EventChirp, AuthorID: uuid.New()})
+	<-fast.C
+	hub.Publish(Event{Type: EventChirp, AuthorID: uuid.New()})
+
+	assert.True(t, slow.Dropped())
+	assert.False(t, fast.Dropped())
+
+	<-slow.C
+	_, open := <-slow.C
+	assert.False(t, open)
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	hub := NewHub(10, 10)
+	sub, _ := hub.Subscribe("")
+	hub.Close()
+	_, open := <-sub.C
+	assert.False(t, open)
+	assert.False(t, sub.Dropped())
+
+	late, backlog := hub.Subscribe("")
+	assert.Empty(t, backlog)
+	_, open = <-late.C
+	assert.False(t, open)
+}
@@ -0,0 +1,40 @@
+package suggest
+
+import "github.com/google/uuid"
+
+// Hashtags indexes which accounts used which hashtags recently, so shared
+// hashtags can be counted for every user from a single read of the chirps
+type Hashtags struct {
+	byUser map[uuid.UUID][]string
+	byTag  map[string][]uuid.UUID
+}
+
+func NewHashtags() *Hashtags {
+	return &Hashtags{
+		byUser: map[uuid.UUID][]string{},
+		byTag:  map[string][]uuid.UUID{},
+	}
+}
+
+// Add records that userID used tag, once per pair. Tags from a protected
+// account aren't public, so they only count towards its own suggestions.
+func (h *Hashtags) Add(userID uuid.UUID, tag string, public bool) {
+	h.byUser[userID] = append(h.byUser[userID], tag)
+	if public {
+		h.byTag[tag] = append(h.byTag[tag], userID)
+	}
+}
+
+// Shared counts, for every other account, how many of userID's hashtags
+// it used in public
+func (h *Hashtags) Shared(userID uuid.UUID) map[uuid.UUID]int64 {
+	shared := map[uuid.UUID]int64{}
+	for _, tag := range h.byUser[userID] {
+		for _, id := range h.byTag[tag] {
+			if id != userID {
+				shared[id]++
+			}
+		}
+	}
+	return shared
+}
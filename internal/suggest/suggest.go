@@ -0,0 +1,61 @@
+package suggest
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// weights for each signal, mutual follows count the most
+const (
+	mutualFollowWeight  = 3
+	sharedHashtagWeight = 2
+	// recent activity only nudges the order, so a very chatty account
+	// can't outrank real overlap on chirp volume alone
+	maxActivityBoost = 10
+)
+
+// Signals are the inputs gathered for one candidate account
+type Signals struct {
+	MutualFollows  int64
+	SharedHashtags int64
+	RecentChirps   int64
+}
+
+type Suggestion struct {
+	UserID uuid.UUID
+	Signals
+	Score int64
+}
+
+func Score(s Signals) int64 {
+	return mutualFollowWeight*s.MutualFollows +
+		sharedHashtagWeight*s.SharedHashtags +
+		min(s.RecentChirps, maxActivityBoost)
+}
+
+// Rank scores the candidates and returns the best limit of them. Accounts
+// that share neither follows nor hashtags with the user are never suggested.
+func Rank(candidates map[uuid.UUID]Signals, limit int) []Suggestion {
+	suggestions := []Suggestion{}
+	for id, signals := range candidates {
+		if signals.MutualFollows == 0 && signals.SharedHashtags == 0 {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{
+			UserID:  id,
+			Signals: signals,
+			Score:   Score(signals),
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].UserID.String() < suggestions[j].UserID.String()
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
@@ -0,0 +1,59 @@
+package suggest
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankOrdersByScore(t *testing.T) {
+	mutual := uuid.New()
+	hashtags := uuid.New()
+	chatty := uuid.New()
+
+	ranked := Rank(map[uuid.UUID]Signals{
+		mutual:   {MutualFollows: 2},
+		hashtags: {SharedHashtags: 1, RecentChirps: 1},
+		chatty:   {SharedHashtags: 1, RecentChirps: 500},
+	}, 10)
+
+	if assert.Len(t, ranked, 3) {
+		assert.Equal(t, chatty, ranked[0].UserID)
+		assert.Equal(t, int64(12), ranked[0].Score)
+		assert.Equal(t, mutual, ranked[1].UserID)
+		assert.Equal(t, hashtags, ranked[2].UserID)
+	}
+}
+
+func TestRankSkipsActivityOnlyCandidates(t *testing.T) {
+	ranked := Rank(map[uuid.UUID]Signals{
+		uuid.New(): {RecentChirps: 50},
+	}, 10)
+	assert.Empty(t, ranked)
+}
+
+func TestRankLimit(t *testing.T) {
+	candidates := map[uuid.UUID]Signals{}
+	for i := 0; i < 5; i++ {
+		candidates[uuid.New()] = Signals{MutualFollows: 1}
+	}
+	assert.Len(t, Rank(candidates, 3), 3)
+}
+
+func TestHashtagsShared(t *testing.T) {
+	me := uuid.New()
+	other := uuid.New()
+	protected := uuid.New()
+
+	h := NewHashtags()
+	h.Add(me, "go", true)
+	h.Add(me, "chirpy", true)
+	h.Add(other, "go", true)
+	h.Add(other, "chirpy", true)
+	h.Add(protected, "go", false)
+
+	assert.Equal(t, map[uuid.UUID]int64{other: 2}, h.Shared(me))
+	// a protected account still gets suggestions from its own tags
+	assert.Equal(t, map[uuid.UUID]int64{me: 1, other: 1}, h.Shared(protected))
+}
@@ -0,0 +1,122 @@
+// Package webhook signs and sends outbound webhook deliveries. Payloads
+// follow the shape Polka sends us: {"event": ..., "data": {...}}.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" over
+	// "<t>.<body>", so receivers can reject replays by checking t
+	SignatureHeader = "X-Chirpy-Signature"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+
+	maxBackoff = 6 * time.Hour
+)
+
+type Payload struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// NewPayload marshals data into a payload body
+func NewPayload(event string, data interface{}) ([]byte, error) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Payload{Event: event, Data: dat})
+}
+
+func mac(secret string, timestamp int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign returns the SignatureHeader value for body sent at timestamp
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := timestamp.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", t, mac(secret, t, body))
+}
+
+// Verify checks a SignatureHeader value, rejecting signatures older than
+// tolerance. It is what a receiver would run.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var (
+		t   int64
+		sig string
+		err error
+	)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "t":
+			t, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.New("webhook: invalid signature timestamp")
+			}
+		case "v1":
+			sig = value
+		}
+	}
+	if t == 0 || sig == "" {
+		return errors.New("webhook: malformed signature header")
+	}
+	if age := time.Since(time.Unix(t, 0)); age > tolerance || age < -tolerance {
+		return errors.New("webhook: signature timestamp out of tolerance")
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, t, body))) {
+		return errors.New("webhook: signature does not match")
+	}
+	return nil
+}
+
+// Backoff is how long to wait before retrying after the given number of
+// failed attempts: 30s, 1m, 2m, ... capped at six hours
+func Backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// Send POSTs a signed payload and returns the response status. Anything
+// but a 2xx is returned as an error alongside the status.
+func Send(ctx context.Context, client *http.Client, url, secret, deliveryID, event string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("webhook: endpoint answered %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
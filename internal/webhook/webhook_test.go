@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"event":"chirp.created","data":{}}`)
+	header := Sign("secret", time.Now(), body)
+	assert.NoError(t, Verify("secret", header, body, 5*time.Minute))
+	assert.Error(t, Verify("other", header, body, 5*time.Minute))
+	assert.Error(t, Verify("secret", header, []byte(`{}`), 5*time.Minute))
+
+	old := Sign("secret", time.Now().Add(-time.Hour), body)
+	assert.Error(t, Verify("secret", old, body, 5*time.Minute))
+	assert.Error(t, Verify("secret", "garbage", body, 5*time.Minute))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, 6*time.Hour, Backoff(30))
+}
+
+func TestSend(t *testing.T) {
+	status := 200
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "chirp.created", r.Header.Get(EventHeader))
+		assert.Equal(t, "d1", r.Header.Get(DeliveryHeader))
+		assert.NoError(t, Verify("secret", r.Header.Get(SignatureHeader), body, time.Minute))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	body, err := NewPayload("chirp.created", map[string]string{"id": "1"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"event":"chirp.created","data":{"id":"1"}}`, string(body))
+
+	code, err := Send(context.Background(), srv.Client(), srv.URL, "secret", "d1", "chirp.created", body)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+
+	status = 503
+	code, err = Send(context.Background(), srv.Client(), srv.URL, "secret", "d1", "chirp.created", body)
+	assert.Error(t, err)
+	assert.Equal(t, 503, code)
+}
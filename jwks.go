@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+)
+
+// jwksCacheAge is how long verifiers may cache the key set. A key has to
+// be published at least this long before it starts signing.
+const jwksCacheAge = 300
+
+// loadSigningKeys reads the comma separated PEM files in JWT_SIGNING_KEYS.
+// The first key signs new access tokens and the rest only verify.
+func loadSigningKeys(paths string) ([]auth.Key, error) {
+	var keys []auth.Key
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := auth.ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// JWKSHandler publishes the public keys access tokens are verified with,
+// so other services can check them without the HS256 secret
+func (cfg *apiConfig) JWKSHandler(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", jwksCacheAge))
+	respondWithPayload(res, 200, cfg.JWTKeys.JWKS())
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxListNameLength = 100
+
+type JsonList struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	IsPrivate bool      `json:"is_private"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func jsonListFromDB(list database.List) JsonList {
+	return JsonList{
+		ID:        list.ID,
+		UserID:    list.UserID,
+		Name:      list.Name,
+		IsPrivate: list.IsPrivate,
+		CreatedAt: list.CreatedAt,
+		UpdatedAt: list.UpdatedAt,
+	}
+}
+
+// listForViewer resolves the {listID} path value. Private lists are
+// reported as missing to everyone but their owner, and only the owner
+// may touch a list when ownerOnly is set.
+func (cfg *apiConfig) listForViewer(res http.ResponseWriter, req *http.Request, viewerId uuid.UUID, ownerOnly bool) (database.List, bool) {
+	listId, err := uuid.Parse(req.PathValue("listID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return database.List{}, false
+	}
+	list, err := cfg.DB.GetListById(req.Context(), listId)
+	if err == sql.ErrNoRows {
+		res.WriteHeader(404)
+		return database.List{}, false
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return database.List{}, false
+	}
+	if list.UserID != viewerId {
+		if list.IsPrivate {
+			res.WriteHeader(404)
+			return database.List{}, false
+		}
+		if ownerOnly {
+			res.WriteHeader(403)
+			return database.List{}, false
+		}
+	}
+	return list, true
+}
+
+func decodeListBody(res http.ResponseWriter, req *http.Request) (string, bool, bool) {
+	ReqBody := struct {
+		Name      string `json:"name"`
+		IsPrivate bool   `json:"is_private"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return "", false, false
+	}
+	if ReqBody.Name == "" || len(ReqBody.Name) > maxListNameLength {
+		respondWithError(res, 400, "List name must be between 1 and 100 characters")
+		return "", false, false
+	}
+	return ReqBody.Name, ReqBody.IsPrivate, true
+}
+
+func (cfg *apiConfig) CreateListHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeListsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	name, isPrivate, ok := decodeListBody(res, req)
+	if !ok {
+		return
+	}
+	list, err := cfg.DB.CreateList(req.Context(), database.CreateListParams{
+		UserID:    userId,
+		Name:      name,
+		IsPrivate: isPrivate,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 201, jsonListFromDB(list))
+}
+
+// GetListsHandler lists the caller's own lists, private ones included
+func (cfg *apiConfig) GetListsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeListsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	lists, err := cfg.DB.GetUserLists(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonList{}
+	for _, list := range lists {
+		ResBody = append(ResBody, jsonListFromDB(list))
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+func (cfg *apiConfig) GetListHandler(res http.ResponseWriter, req *http.Request) {
+	list, ok := cfg.listForViewer(res, req, cfg.viewerID(req, scopeListsRead), false)
+	if !ok {
+		return
+	}
+	respondWithPayload(res, 200, jsonListFromDB(list))
+}
+
+func (cfg *apiConfig) UpdateListHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeListsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	list, ok := cfg.listForViewer(res, req, userId, true)
+	if !ok {
+		return
+	}
+	name, isPrivate, ok := decodeListBody(res, req)
+	if !ok {
+		return
+	}
+	list, err = cfg.DB.UpdateList(req.Context(), database.UpdateListParams{
+		Name:      name,
+		IsPrivate: isPrivate,
+		ID:        list.ID,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 200, jsonListFromDB(list))
+}
+
+func (cfg *apiConfig) DeleteListHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeListsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	list, ok := cfg.listForViewer(res, req, userId, true)
+	if !ok {
+		return
+	}
+	if err := cfg.DB.DeleteList(req.Context(), list.ID); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) GetListMembersHandler(res http.ResponseWriter, req *http.Request) {
+	list, ok := cfg.listForViewer(res, req, cfg.viewerID(req, scopeListsRead), false)
+	if !ok {
+		return
+	}
+	members, err := cfg.DB.GetListMembers(req.Context(), list.ID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []uuid.UUID{}
+	for _, member := range members {
+		ResBody = append(ResBody, member.UserID)
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+func (cfg *apiConfig) AddListMemberHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeListsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	list, ok := cfg.listForViewer(res, req, userId, true)
+	if !ok {
+		return
+	}
+	ReqBody := struct {
+		UserID uuid.UUID `json:"user_id"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if _, err := cfg.DB.GetUserById(req.Context(), ReqBody.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(res, 404, "user not found")
+			return
+		}
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if err := cfg.DB.AddListMember(req.Context(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: ReqBody.UserID,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) RemoveListMemberHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeListsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	list, ok := cfg.listForViewer(res, req, userId, true)
+	if !ok {
+		return
+	}
+	memberId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.RemoveListMember(req.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+// GetListChirpsHandler is the list's timeline: chirps from its members only,
+// ordered like GET /api/chirps and with the same protected account rules
+func (cfg *apiConfig) GetListChirpsHandler(res http.ResponseWriter, req *http.Request) {
+	viewerId := cfg.viewerID(req, scopeListsRead)
+	list, ok := cfg.listForViewer(res, req, viewerId, false)
+	if !ok {
+		return
+	}
+	chirps, err := cfg.DB.GetListChirps(req.Context(), database.GetListChirpsParams{
+		ListID:   list.ID,
+		ViewerID: viewerId,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	cfg.recordImpressions(viewerId, chirps...)
+	ChirpsResBody := []JsonChirp{}
+	for _, chirp := range chirps {
+		ChirpsResBody = append(ChirpsResBody, jsonChirpFromDB(chirp))
+	}
+	if err := cfg.withPreviews(req.Context(), ChirpsResBody); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 200, ChirpsResBody)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/mail"
+)
+
+const (
+	magicLinkTTL    = 15 * time.Minute
+	magicLinkCookie = "chirpy_magic_nonce"
+	// no more than magicLinkLimit links are sent to an email per
+	// magicLinkWindow
+	magicLinkLimit  = 3
+	magicLinkWindow = 15 * time.Minute
+)
+
+type JsonMagicLinkRequest struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// setMagicLinkNonce stores the nonce that binds login links to this
+// browser. The cookie only goes to the magic link endpoints.
+func (cfg *apiConfig) setMagicLinkNonce(res http.ResponseWriter, nonce string, maxAge time.Duration) {
+	http.SetCookie(res, &http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    nonce,
+		Path:     "/api/login/magic",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// allowMagicLink counts a request for a link to email and reports whether
+// it is within the rate limit. Requests for emails without an account
+// count the same, so the limit doesn't tell which emails exist.
+func (cfg *apiConfig) allowMagicLink(ctx context.Context, email string) (bool, error) {
+	since := time.Now().UTC().Add(-magicLinkWindow)
+	if err := cfg.DB.DeleteMagicLinkRequestsBefore(ctx, since); err != nil {
+		return false, err
+	}
+	if err := cfg.DB.CreateMagicLinkRequest(ctx, email); err != nil {
+		return false, err
+	}
+	count, err := cfg.DB.CountMagicLinkRequests(ctx, database.CountMagicLinkRequestsParams{
+		Email:     email,
+		CreatedAt: since,
+	})
+	return count <= magicLinkLimit, err
+}
+
+// sendMagicLink emails a login link bound to nonce if email belongs to an
+// account. Like requestPasswordReset it runs after the response is sent.
+func (cfg *apiConfig) sendMagicLink(email, nonce string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	user, err := cfg.DB.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("error looking up %s for a login link: %v", email, err)
+		return
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("error generating a login link: %v", err)
+		return
+	}
+	if err := cfg.DB.CreateMagicLink(ctx, database.CreateMagicLinkParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		NonceHash: auth.HashToken(nonce),
+		ExpiresAt: time.Now().UTC().Add(magicLinkTTL),
+		Email:     user.Email,
+	}); err != nil {
+		log.Printf("error storing a login link: %v", err)
+		return
+	}
+	if err := cfg.DB.DeleteExpiredMagicLinks(ctx); err != nil {
+		log.Printf("error deleting expired login links: %v", err)
+	}
+	link := cfg.BaseURL + "/api/login/magic/verify?token=" + url.QueryEscape(token)
+	cfg.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy login link",
+		Body: fmt.Sprintf("Open this link within 15 minutes, in the browser you asked for it from, to log in to Chirpy:\n\n%s\n\n"+
+			"If you didn't ask for it, ignore this email.\n", link),
+	})
+}
+
+// MagicLinkHandler emails a single-use login link. The answer is the
+// same whether or not the email has an account.
+func (cfg *apiConfig) MagicLinkHandler(res http.ResponseWriter, req *http.Request) {
+	ReqBody := struct {
+		Email string `json:"email"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	email := strings.TrimSpace(ReqBody.Email)
+	allowed, err := cfg.allowMagicLink(req.Context(), strings.ToLower(email))
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if !allowed {
+		res.Header().Set("Retry-After", strconv.Itoa(int(magicLinkWindow.Seconds())))
+		respondWithError(res, 429, "too many login links requested, try again later")
+		return
+	}
+	// a browser asking again keeps its nonce, so the links sent earlier
+	// keep working
+	var nonce string
+	if cookie, err := req.Cookie(magicLinkCookie); err == nil && len(cookie.Value) == 64 {
+		nonce = cookie.Value
+	} else if nonce, err = auth.MakeRefreshToken(); err != nil {
+		respondWithError(res, 500, "unable to generate token")
+		return
+	}
+	cfg.setMagicLinkNonce(res, nonce, magicLinkTTL)
+	go cfg.sendMagicLink(email, nonce)
+	respondWithPayload(res, 202, JsonMagicLinkRequest{
+		Nonce:     nonce,
+		ExpiresAt: time.Now().UTC().Add(magicLinkTTL),
+	})
+}
+
+// MagicLinkVerifyHandler is where the emailed link leads. It logs in
+// like LoginHandler, as long as the browser holds the nonce the link was
+// requested with.
+func (cfg *apiConfig) MagicLinkVerifyHandler(res http.ResponseWriter, req *http.Request) {
+	cookie, err := req.Cookie(magicLinkCookie)
+	if err != nil {
+		respondWithError(res, 401, "open the link in the browser that asked for it")
+		return
+	}
+	link, err := cfg.DB.UseMagicLink(req.Context(), database.UseMagicLinkParams{
+		TokenHash: auth.HashToken(req.URL.Query().Get("token")),
+		NonceHash: auth.HashToken(cookie.Value),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(res, 401, "invalid or expired login link")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	cfg.setMagicLinkNonce(res, "", -time.Second)
+	user, err := cfg.DB.GetUserById(req.Context(), link.UserID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	// a link sent before the email was changed went to an address the
+	// account no longer has, so it neither logs in nor verifies anything
+	if user.Email != link.Email {
+		respondWithError(res, 401, "invalid or expired login link")
+		return
+	}
+	// the link was read from the inbox, which proves the address
+	if _, err := cfg.DB.MarkEmailVerified(req.Context(), database.MarkEmailVerifiedParams{
+		ID:    user.ID,
+		Email: link.Email,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	totp, enabled, err := cfg.twoFactorSecret(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if enabled {
+		cfg.startLoginChallenge(res, req, totp.UserID)
+		return
+	}
+	cfg.respondWithLogin(res, req, user)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/mail"
+)
+
+const (
+	mailTimeout     = 30 * time.Second
+	defaultMailDir  = "mail"
+	defaultMailFrom = "chirpy@localhost"
+)
+
+// loadMailer picks the mailer from MAILER: "smtp" sends through SMTP_ADDR,
+// "memory" keeps messages in memory, and anything else writes them as
+// files to MAIL_DIR for development
+func loadMailer() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = defaultMailFrom
+	}
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		return &mail.SMTPMailer{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "memory":
+		return mail.NewMemoryMailer(), nil
+	}
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = defaultMailDir
+	}
+	return mail.NewFileMailer(dir, from)
+}
+
+// sendMail sends msg in the background, so a slow mail server doesn't
+// hold up the request
+func (cfg *apiConfig) sendMail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := cfg.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("error sending %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
@@ -1,18 +1,28 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/P-H-Pancholi/Chirpy/internal/activitypub"
 	"github.com/P-H-Pancholi/Chirpy/internal/auth"
 	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/eventbus"
+	"github.com/P-H-Pancholi/Chirpy/internal/impressions"
+	"github.com/P-H-Pancholi/Chirpy/internal/mail"
+	"github.com/P-H-Pancholi/Chirpy/internal/preview"
+	"github.com/P-H-Pancholi/Chirpy/internal/stream"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -24,13 +34,43 @@ type JsonChirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	// Preview is filled in by withPreviews once the chirp's first link has
+	// been fetched
+	Preview *JsonLinkPreview `json:"preview,omitempty"`
+}
+
+func jsonChirpFromDB(chirp database.Chirp) JsonChirp {
+	return JsonChirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
 }
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	DB             database.Queries
+	Conn           *sql.DB
+	Bus            eventbus.Bus
+	Stream         *stream.Hub
+	Sockets        *wsServer
+	APClient       *http.Client
+	Deliveries     *activitypub.Queue
+	Impressions    *impressions.Recorder
+	Previews       *preview.Fetcher
 	Platform       string
-	JwtToken       string
+	JWTKeys        *auth.KeySet
+	// AccessTokenTTL and RefreshTokenTTL are how long new tokens last
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	AdminAPIKey     string
+	BaseURL         string
+	Mailer          mail.Mailer
+	// VerifiedEmailRequired holds the actions only users with a verified
+	// email may take
+	VerifiedEmailRequired map[string]bool
 }
 
 // wrapper function should return another function with logic intended included
@@ -41,6 +81,51 @@ func (cfg *apiConfig) middlewareMetricInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticate returns the user behind the request's bearer token, which
+// is either an access JWT or a personal access token, as long as the token
+// grants scope
+func (cfg *apiConfig) authenticate(req *http.Request, scope string) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if auth.IsPersonalAccessToken(token) {
+		return cfg.authenticatePAT(req.Context(), token, scope)
+	}
+	claims, err := cfg.validateAccessToken(req.Context(), token)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if !claims.HasScope(scope) {
+		return uuid.UUID{}, errInsufficientScope
+	}
+	return claims.UserID()
+}
+
+// withTx runs fn inside a single database transaction, rolling back if
+// fn returns an error
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(cfg.DB.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// viewerID is like authenticate but for endpoints that are also open to
+// anonymous callers, for whom it returns uuid.Nil
+func (cfg *apiConfig) viewerID(req *http.Request, scope string) uuid.UUID {
+	userId, err := cfg.authenticate(req, scope)
+	if err != nil {
+		return uuid.Nil
+	}
+	return userId
+}
 func main() {
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
@@ -55,36 +140,205 @@ func main() {
 		Handler: mux,
 	}
 
+	// EVENT_BUS=postgres shares events between instances through
+	// LISTEN/NOTIFY, otherwise they stay in this process
+	var bus eventbus.Bus = eventbus.NewMemoryBus()
+	if os.Getenv("EVENT_BUS") == "postgres" {
+		bus, err = eventbus.NewPostgresBus(db, dbURL, eventBusChannel)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	defer bus.Close()
+
 	platform := os.Getenv("PLATFORM")
+	// BASE_URL is the public address used in absolute links such as feed ids
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	// JWT_SIGNING_KEYS switches access tokens from HS256 with JWT_TOKEN to
+	// the asymmetric keys in these PEM files
+	signingKeys, err := loadSigningKeys(os.Getenv("JWT_SIGNING_KEYS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	jwtKeys := auth.NewKeySet(os.Getenv("JWT_TOKEN"), signingKeys...)
+	jwtKeys.Leeway = jwtLeeway
+	accessTokenTTL, err := durationEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	refreshTokenTTL, err := durationEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mailer, err := loadMailer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	verifiedEmailRequired, err := loadVerifiedEmailActions(os.Getenv("REQUIRE_VERIFIED_EMAIL"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// actor, object and inbox URLs come from remote servers and users
+	apClient := preview.NewPublicClient(federationTimeout)
+	deliveries := activitypub.NewQueue(apClient, deliveryRetryBase, deliveryMaxAttempts)
+	defer deliveries.Close()
 	cfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		DB:             *dbQueries,
-		Platform:       platform,
-		JwtToken:       os.Getenv("JWT_TOKEN"),
+		fileserverHits:        atomic.Int32{},
+		DB:                    *dbQueries,
+		Conn:                  db,
+		Bus:                   bus,
+		Stream:                stream.NewHub(streamHistorySize, streamBufferSize),
+		Sockets:               newWSServer(),
+		APClient:              apClient,
+		Deliveries:            deliveries,
+		Previews:              preview.NewFetcher(previewConcurrency),
+		Platform:              platform,
+		JWTKeys:               jwtKeys,
+		AccessTokenTTL:        accessTokenTTL,
+		RefreshTokenTTL:       refreshTokenTTL,
+		AdminAPIKey:           os.Getenv("ADMIN_API_KEY"),
+		BaseURL:               baseURL,
+		Mailer:                mailer,
+		VerifiedEmailRequired: verifiedEmailRequired,
 	}
 
 	cfg.fileserverHits.Store(0)
-
-	mux.Handle("/app/", cfg.middlewareMetricInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
-	// mux.Handle("/app/", http.FileServer(http.Dir(".")))
+	cfg.Bus.Subscribe(cfg.relayToStream)
+	cfg.Impressions = impressions.NewRecorder(cfg.flushImpressions, impressionsMaxPending)
+
+	app := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, cfg.middlewareMetricInc(handler))
+	}
+	app("GET /app/{$}", cfg.WebTimelineHandler)
+	app("GET /app/chirps/{chirpID}", cfg.WebChirpHandler)
+	app("POST /app/chirps", cfg.WebCreateChirpHandler)
+	app("GET /app/users/{userID}", cfg.WebProfileHandler)
+	app("GET /app/login", cfg.WebLoginPageHandler)
+	app("POST /app/login", cfg.WebLoginHandler)
+	app("GET /app/signup", cfg.WebSignupPageHandler)
+	app("POST /app/signup", cfg.WebSignupHandler)
+	app("POST /app/logout", cfg.WebLogoutHandler)
+	app("GET /app/password/forgot", cfg.WebForgotPasswordPageHandler)
+	app("POST /app/password/forgot", cfg.WebForgotPasswordHandler)
+	app("GET /app/password/reset", cfg.WebResetPasswordPageHandler)
+	app("POST /app/password/reset", cfg.WebResetPasswordHandler)
+	app("/app/", cfg.WebNotFoundHandler)
+	mux.Handle("/app/assets/", cfg.middlewareMetricInc(http.StripPrefix("/app/assets", http.FileServer(http.Dir("assets")))))
 	mux.HandleFunc("GET /api/healthz", HealthHandler)
 	mux.HandleFunc("GET /admin/metrics", cfg.NumRequestHandler)
 	mux.HandleFunc("POST /admin/reset", cfg.ResetHandler)
+	mux.HandleFunc("POST /admin/tokens/revoke", cfg.AdminRevokeAccessTokenHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/suspend", cfg.AdminSuspendUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspend", cfg.AdminUnsuspendUserHandler)
 	mux.HandleFunc("POST /api/validate_chirp", ValidateChirp)
 	mux.HandleFunc("POST  /api/users", cfg.CreateUserHandler)
 	mux.HandleFunc("POST /api/chirps", cfg.ChirpHandler)
 	mux.HandleFunc("GET /api/chirps", cfg.GetAllChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirp_id}", cfg.GetChirpHandler)
 	mux.HandleFunc("POST  /api/login", cfg.LoginHandler)
+	mux.HandleFunc("POST /api/login/2fa", cfg.Login2FAHandler)
+	mux.HandleFunc("POST /api/login/magic", cfg.MagicLinkHandler)
+	mux.HandleFunc("GET /api/login/magic/verify", cfg.MagicLinkVerifyHandler)
+	mux.HandleFunc("POST /api/password/forgot", cfg.ForgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", cfg.ResetPasswordHandler)
+	mux.HandleFunc("GET /api/verify-email", cfg.VerifyEmailHandler)
+	mux.HandleFunc("POST /api/verify-email", cfg.ResendVerificationEmailHandler)
 	mux.HandleFunc("POST /api/refresh", cfg.RefreshHandler)
 	mux.HandleFunc("POST /api/revoke", cfg.RevokeHandler)
 	mux.HandleFunc("PUT /api/users", cfg.UpdateUserHandler)
+	mux.HandleFunc("GET /api/sessions", cfg.GetSessionsHandler)
+	mux.HandleFunc("DELETE /api/sessions", cfg.RevokeAllSessionsHandler)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.RevokeSessionHandler)
+	mux.HandleFunc("POST /api/tokens", cfg.CreatePersonalAccessTokenHandler)
+	mux.HandleFunc("GET /api/tokens", cfg.GetPersonalAccessTokensHandler)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.DeletePersonalAccessTokenHandler)
+	mux.HandleFunc("GET /api/2fa", cfg.GetTwoFactorHandler)
+	mux.HandleFunc("POST /api/2fa/enroll", cfg.EnrollTwoFactorHandler)
+	mux.HandleFunc("POST /api/2fa/confirm", cfg.ConfirmTwoFactorHandler)
+	mux.HandleFunc("POST /api/2fa/recovery_codes", cfg.RegenerateRecoveryCodesHandler)
+	mux.HandleFunc("POST /api/2fa/disable", cfg.DisableTwoFactorHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.DeleteChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/analytics", cfg.ChirpAnalyticsHandler)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.PolkaWebhookHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.FollowHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.UnfollowHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", cfg.BlockHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", cfg.UnblockHandler)
+	mux.HandleFunc("GET /api/blocks", cfg.GetBlocksHandler)
+	mux.HandleFunc("GET /api/follow_requests", cfg.GetFollowRequestsHandler)
+	mux.HandleFunc("POST /api/follow_requests/{followerID}/approve", cfg.ApproveFollowRequestHandler)
+	mux.HandleFunc("POST /api/follow_requests/{followerID}/deny", cfg.DenyFollowRequestHandler)
+	mux.HandleFunc("GET /api/notifications", cfg.GetNotificationsHandler)
+	mux.HandleFunc("POST /api/notifications/read", cfg.MarkNotificationsReadHandler)
+	mux.HandleFunc("POST /api/conversations", cfg.CreateConversationHandler)
+	mux.HandleFunc("GET /api/conversations", cfg.GetConversationsHandler)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", cfg.CreateMessageHandler)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", cfg.GetMessagesHandler)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", cfg.MarkConversationReadHandler)
+	mux.HandleFunc("POST /api/lists", cfg.CreateListHandler)
+	mux.HandleFunc("GET /api/lists", cfg.GetListsHandler)
+	mux.HandleFunc("GET /api/lists/{listID}", cfg.GetListHandler)
+	mux.HandleFunc("PUT /api/lists/{listID}", cfg.UpdateListHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}", cfg.DeleteListHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/members", cfg.GetListMembersHandler)
+	mux.HandleFunc("POST /api/lists/{listID}/members", cfg.AddListMemberHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", cfg.RemoveListMemberHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", cfg.GetListChirpsHandler)
+	mux.HandleFunc("GET /api/users/suggestions", cfg.GetSuggestionsHandler)
+	mux.HandleFunc("GET /api/stream", cfg.StreamHandler)
+	mux.HandleFunc("GET /api/ws", cfg.WSHandler)
+	mux.HandleFunc("GET /feeds/users/{file}", cfg.UserFeedHandler)
+	mux.HandleFunc("GET /feeds/hashtags/{file}", cfg.HashtagFeedHandler)
+	mux.HandleFunc("GET /api/oembed", cfg.OEmbedHandler)
+	mux.HandleFunc("GET /embed/chirps/{chirpID}", cfg.EmbedChirpHandler)
+	mux.HandleFunc("GET /.well-known/webfinger", cfg.WebFingerHandler)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.JWKSHandler)
+	mux.HandleFunc("GET /ap/users/{userID}", cfg.ActorHandler)
+	mux.HandleFunc("POST /ap/users/{userID}/inbox", cfg.InboxHandler)
+	mux.HandleFunc("GET /ap/users/{userID}/outbox", cfg.OutboxHandler)
+	mux.HandleFunc("GET /ap/chirps/{chirpID}", cfg.NoteHandler)
+	mux.HandleFunc("POST /api/federation/follow", cfg.FederatedFollowHandler)
+	mux.HandleFunc("POST /api/federation/like", cfg.FederatedLikeHandler)
+	mux.HandleFunc("POST /api/webhooks", cfg.CreateWebhookHandler)
+	mux.HandleFunc("GET /api/webhooks", cfg.GetWebhooksHandler)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.DeleteWebhookHandler)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/enable", cfg.EnableWebhookHandler)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.GetWebhookDeliveriesHandler)
+
+	go cfg.runSuggestionsJob(suggestionsInterval)
+	go cfg.runWebhookWorker(webhookPollInterval)
+
+	// on SIGINT/SIGTERM close websockets and end streams, which would
+	// otherwise hold Shutdown up, and let in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server.RegisterOnShutdown(cfg.Sockets.Shutdown)
+	server.RegisterOnShutdown(cfg.Stream.Close)
+	impressionsDone := make(chan struct{})
+	go func() {
+		defer close(impressionsDone)
+		cfg.Impressions.Run(ctx, impressionsFlushInterval)
+	}()
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Println(err)
+		}
+	}()
 
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		fmt.Println(err)
+		return
 	}
+	<-shutdownDone
+	<-impressionsDone
 }
 
 func (cfg *apiConfig) PolkaWebhookHandler(res http.ResponseWriter, req *http.Request) {
@@ -104,32 +358,53 @@ func (cfg *apiConfig) PolkaWebhookHandler(res http.ResponseWriter, req *http.Req
 		res.WriteHeader(204)
 		return
 	}
-	result, err := cfg.DB.MarkUserRed(req.Context(), ReqBody.Data.UserId)
-	if err != nil {
-		respondWithError(res, 500, err.Error())
+	// the user has paid, so the upgrade is always recorded; chirpyRed
+	// decides whether it takes effect yet
+	var (
+		rowsAffected int64
+		notification database.Notification
+	)
+	err := cfg.withTx(req.Context(), func(q *database.Queries) error {
+		result, err := q.MarkUserRed(req.Context(), ReqBody.Data.UserId)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			// Polka resends the webhook, so only the first upgrade notifies
+			_, err = q.GetUserById(req.Context(), ReqBody.Data.UserId)
+			return err
+		}
+		notification, err = q.CreateNotification(req.Context(), database.CreateNotificationParams{
+			UserID: ReqBody.Data.UserId,
+			Type:   notificationChirpyRed,
+		})
+		return err
+	})
+	if err == sql.ErrNoRows {
+		res.WriteHeader(404)
 		return
 	}
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		respondWithError(res, 500, err.Error())
 		return
 	}
 	if rowsAffected == 0 {
-		res.WriteHeader(404)
+		res.WriteHeader(204)
 		return
 	}
+	cfg.publish(req, eventbus.UserUpgraded, userEventData{UserID: ReqBody.Data.UserId})
+	cfg.publishNotification(req, notification)
 	res.WriteHeader(204)
 }
 
 func (cfg *apiConfig) DeleteChirpHandler(res http.ResponseWriter, req *http.Request) {
-	token, err := auth.GetBearerToken(req.Header)
+	userId, err := cfg.authenticate(req, scopeChirpsWrite)
 	if err != nil {
-		respondWithError(res, 401, err.Error())
-		return
-	}
-	userId, err := auth.ValidateJWT(token, cfg.JwtToken)
-	if err != nil {
-		respondWithError(res, 401, err.Error())
+		respondToAuthError(res, err)
 		return
 	}
 	if err := uuid.Validate(req.PathValue("chirpID")); err != nil {
@@ -149,23 +424,25 @@ func (cfg *apiConfig) DeleteChirpHandler(res http.ResponseWriter, req *http.Requ
 		respondWithError(res, 500, err.Error())
 		return
 	}
+	cfg.publishChirpEvent(req, eventbus.ChirpDeleted, jsonChirpFromDB(chirp))
+	cfg.federateChirp(req, activitypub.TypeDelete, jsonChirpFromDB(chirp))
 	res.WriteHeader(204)
 }
 
+// UpdateUserHandler changes the caller's email and password, which are
+// sent together, and whether the account is protected. The credentials
+// need a full login; a personal access token with profile:write may only
+// change is_protected, or it could take the account over.
 func (cfg *apiConfig) UpdateUserHandler(res http.ResponseWriter, req *http.Request) {
-	token, err := auth.GetBearerToken(req.Header)
-	if err != nil {
-		respondWithError(res, 401, err.Error())
-		return
-	}
-	userId, err := auth.ValidateJWT(token, cfg.JwtToken)
+	userId, err := cfg.authenticate(req, scopeProfileWrite)
 	if err != nil {
-		respondWithError(res, 401, err.Error())
+		respondToAuthError(res, err)
 		return
 	}
 	ReqBody := struct {
 		Email          string `json:"email"`
 		HashedPassword string `json:"password"`
+		IsProtected    *bool  `json:"is_protected"`
 	}{}
 	decoder := json.NewDecoder(req.Body)
 	defer req.Body.Close()
@@ -173,33 +450,98 @@ func (cfg *apiConfig) UpdateUserHandler(res http.ResponseWriter, req *http.Reque
 		respondWithError(res, 500, err.Error())
 		return
 	}
-	hashed_pass, err := auth.HashPassword(ReqBody.HashedPassword)
-	if err != nil {
-		respondWithError(res, 500, err.Error())
-		return
+	changesCredentials := ReqBody.Email != "" || ReqBody.HashedPassword != ""
+	if changesCredentials {
+		if _, err := cfg.authenticate(req, scopeAccount); err != nil {
+			respondToAuthError(res, err)
+			return
+		}
+		if !validEmail(ReqBody.Email) {
+			respondWithError(res, 400, "invalid email")
+			return
+		}
+		if ReqBody.HashedPassword == "" {
+			respondWithError(res, 400, "password is required")
+			return
+		}
 	}
-	User, err := cfg.DB.UpdateUserById(req.Context(), database.UpdateUserByIdParams{
-		UpdatedAt:      time.Now().UTC(),
-		Email:          ReqBody.Email,
-		HashedPassword: hashed_pass,
-		ID:             userId,
-	})
+	User, err := cfg.DB.GetUserById(req.Context(), userId)
 	if err != nil {
 		respondWithError(res, 500, err.Error())
 		return
 	}
+	if changesCredentials {
+		hashed_pass, err := auth.HashPassword(ReqBody.HashedPassword)
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		previousEmail := User.Email
+		User, err = cfg.DB.UpdateUserById(req.Context(), database.UpdateUserByIdParams{
+			UpdatedAt:      time.Now().UTC(),
+			Email:          ReqBody.Email,
+			HashedPassword: hashed_pass,
+			ID:             userId,
+		})
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		if User.Email != previousEmail {
+			cfg.sendVerificationEmail(User.ID, User.Email)
+		}
+	}
+	if ReqBody.IsProtected != nil {
+		User, err = cfg.DB.SetUserProtected(req.Context(), database.SetUserProtectedParams{
+			IsProtected: *ReqBody.IsProtected,
+			ID:          userId,
+		})
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		// going public lets everyone in, so waiting requests no longer need a decision
+		if !User.IsProtected {
+			notifications := []database.Notification{}
+			err := cfg.withTx(req.Context(), func(q *database.Queries) error {
+				followerIds, err := q.ApproveAllFollowRequests(req.Context(), userId)
+				if err != nil {
+					return err
+				}
+				for _, followerId := range followerIds {
+					n, err := notifyFollowApproved(req.Context(), q, followerId, userId)
+					if err != nil {
+						return err
+					}
+					notifications = append(notifications, n)
+				}
+				return nil
+			})
+			if err != nil {
+				respondWithError(res, 500, err.Error())
+				return
+			}
+			for _, n := range notifications {
+				cfg.publishNotification(req, n)
+			}
+		}
+	}
 	JsonUser := struct {
-		ID          uuid.UUID `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		EmailVerified bool      `json:"email_verified"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		IsProtected   bool      `json:"is_protected"`
 	}{
-		ID:          User.ID,
-		CreatedAt:   User.CreatedAt,
-		UpdatedAt:   User.UpdatedAt,
-		Email:       User.Email,
-		IsChirpyRed: User.IsChirpyRed,
+		ID:            User.ID,
+		CreatedAt:     User.CreatedAt,
+		UpdatedAt:     User.UpdatedAt,
+		Email:         User.Email,
+		EmailVerified: User.EmailVerifiedAt.Valid,
+		IsChirpyRed:   cfg.chirpyRed(User),
+		IsProtected:   User.IsProtected,
 	}
 	dat, err := json.Marshal(JsonUser)
 	if err != nil {
@@ -216,12 +558,24 @@ func (cfg *apiConfig) RevokeHandler(res http.ResponseWriter, req *http.Request)
 		respondWithError(res, 401, err.Error())
 		return
 	}
+	// the client may also send its access token, which then stops working
+	// right away instead of when it expires. Tokens that are already
+	// invalid are left alone. The body is read first so a bad one revokes
+	// nothing.
+	ReqBody := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&ReqBody); err != nil && err != io.EOF {
+		respondWithError(res, 400, "invalid body")
+		return
+	}
 	err = cfg.DB.RevokeToken(req.Context(), database.RevokeTokenParams{
 		RevokedAt: sql.NullTime{
 			Time:  time.Now().UTC(),
 			Valid: true,
 		},
-		Token: token,
+		TokenHash: auth.HashToken(token),
 	})
 
 	if err != nil {
@@ -232,6 +586,12 @@ func (cfg *apiConfig) RevokeHandler(res http.ResponseWriter, req *http.Request)
 		respondWithError(res, 401, err.Error())
 		return
 	}
+	if ReqBody.AccessToken != "" {
+		if err := cfg.revokeAccessTokenString(req.Context(), ReqBody.AccessToken); err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+	}
 	res.WriteHeader(204)
 }
 
@@ -241,25 +601,46 @@ func (cfg *apiConfig) RefreshHandler(res http.ResponseWriter, req *http.Request)
 		respondWithError(res, 401, err.Error())
 		return
 	}
-	RefreshTokenResponse, err := cfg.DB.GetUserFromRefreshToken(req.Context(), token)
+	RefreshTokenResponse, err := cfg.DB.GetUserFromRefreshToken(req.Context(), auth.HashToken(token))
 	if err != nil {
 		respondWithError(res, 401, fmt.Sprintf("error in fetching user from DB : %v", err))
 		return
 	}
+	if RefreshTokenResponse.RevokedAt.Valid {
+		respondWithError(res, 401, fmt.Sprintf("refresh token revoked"))
+		return
+	}
 	if RefreshTokenResponse.ExpiresAt.Compare(time.Now().UTC()) <= 0 {
 		respondWithError(res, 401, fmt.Sprintf("refresh token expired"))
 		return
 	}
-	if RefreshTokenResponse.RevokedAt.Valid {
-		respondWithError(res, 401, fmt.Sprintf("refresh token revoked"))
+	// a token that was already rotated can only be presented again by
+	// someone who copied it, so the whole login is revoked
+	refreshToken, err := cfg.rotateRefreshToken(req, RefreshTokenResponse)
+	if err == errRefreshTokenReused {
+		if err := cfg.revokeRefreshTokenFamily(req.Context(), RefreshTokenResponse.FamilyID); err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		respondWithError(res, 401, "refresh token reused")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	accessToken, err := cfg.makeAccessToken(RefreshTokenResponse.UserID)
+	if err != nil {
+		respondWithError(res, 500, "unable to generate token")
 		return
 	}
-	accessToken, err := auth.MakeJWT(RefreshTokenResponse.UserID, cfg.JwtToken)
 
 	ResBody := struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
 	}
 
 	dat, err := json.Marshal(ResBody)
@@ -291,22 +672,27 @@ func (cfg *apiConfig) LoginHandler(res http.ResponseWriter, req *http.Request) {
 		respondWithError(res, 401, "Incorrect email or password")
 		return
 	}
+	totp, enabled, err := cfg.twoFactorSecret(req.Context(), currUser.ID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if enabled {
+		cfg.startLoginChallenge(res, req, totp.UserID)
+		return
+	}
+	cfg.respondWithLogin(res, req, currUser)
+}
 
-	refresh_token, _ := auth.MakeRefreshToken()
-
-	Rt, err := cfg.DB.CreateRefereshToken(req.Context(), database.CreateRefereshTokenParams{
-		Token:     refresh_token,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-		ExpiresAt: time.Now().UTC().AddDate(0, 0, 60),
-		UserID:    currUser.ID,
-	})
+// respondWithLogin starts a session for user and answers with its tokens
+func (cfg *apiConfig) respondWithLogin(res http.ResponseWriter, req *http.Request, currUser database.User) {
+	refreshToken, err := cfg.startSession(req, currUser.ID)
 	if err != nil {
 		respondWithError(res, 500, "unable to generate token")
 		return
 	}
 
-	token, err := auth.MakeJWT(currUser.ID, cfg.JwtToken)
+	token, err := cfg.makeAccessToken(currUser.ID)
 	if err != nil {
 		respondWithError(res, 500, "unable to generate token")
 		return
@@ -325,8 +711,8 @@ func (cfg *apiConfig) LoginHandler(res http.ResponseWriter, req *http.Request) {
 		UpdatedAt:    currUser.UpdatedAt,
 		Email:        currUser.Email,
 		Token:        token,
-		RefreshToken: Rt.Token,
-		IsChirpyRed:  currUser.IsChirpyRed,
+		RefreshToken: refreshToken,
+		IsChirpyRed:  cfg.chirpyRed(currUser),
 	}
 	dat, err := json.Marshal(JsonUser)
 	if err != nil {
@@ -335,11 +721,21 @@ func (cfg *apiConfig) LoginHandler(res http.ResponseWriter, req *http.Request) {
 	}
 	res.WriteHeader(200)
 	res.Write(dat)
-
 }
 
 func (cfg *apiConfig) GetChirpHandler(res http.ResponseWriter, req *http.Request) {
-	chirp, err := cfg.DB.GetChirpById(req.Context(), uuid.MustParse(req.PathValue("chirp_id")))
+	chirpId, err := uuid.Parse(req.PathValue("chirp_id"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	// chirps from protected accounts are reported as missing to anyone
+	// who is not an approved follower
+	viewerId := cfg.viewerID(req, scopeChirpsRead)
+	chirp, err := cfg.DB.GetVisibleChirpById(req.Context(), database.GetVisibleChirpByIdParams{
+		ID:       chirpId,
+		ViewerID: viewerId,
+	})
 	if err == sql.ErrNoRows {
 		res.WriteHeader(404)
 		return
@@ -348,14 +744,13 @@ func (cfg *apiConfig) GetChirpHandler(res http.ResponseWriter, req *http.Request
 		respondWithError(res, 500, err.Error())
 		return
 	}
-	chirpResBody := JsonChirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	cfg.recordImpressions(viewerId, chirp)
+	chirpResBody := []JsonChirp{jsonChirpFromDB(chirp)}
+	if err := cfg.withPreviews(req.Context(), chirpResBody); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
 	}
-	dat, err := json.Marshal(chirpResBody)
+	dat, err := json.Marshal(chirpResBody[0])
 	if err != nil {
 		fmt.Println(err)
 		respondWithError(res, 500, err.Error())
@@ -367,21 +762,21 @@ func (cfg *apiConfig) GetChirpHandler(res http.ResponseWriter, req *http.Request
 }
 
 func (cfg *apiConfig) GetAllChirpsHandler(res http.ResponseWriter, req *http.Request) {
-	chirps, err := cfg.DB.GetAllChirps(req.Context())
+	viewerId := cfg.viewerID(req, scopeChirpsRead)
+	chirps, err := cfg.DB.GetVisibleChirps(req.Context(), viewerId)
 	if err != nil {
 		fmt.Println(err)
 		respondWithError(res, 500, err.Error())
 		return
 	}
+	cfg.recordImpressions(viewerId, chirps...)
 	ChirpsResBody := []JsonChirp{}
 	for _, chirp := range chirps {
-		ChirpsResBody = append(ChirpsResBody, JsonChirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+		ChirpsResBody = append(ChirpsResBody, jsonChirpFromDB(chirp))
+	}
+	if err := cfg.withPreviews(req.Context(), ChirpsResBody); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
 	}
 	dat, err := json.Marshal(ChirpsResBody)
 	if err != nil {
@@ -393,13 +788,33 @@ func (cfg *apiConfig) GetAllChirpsHandler(res http.ResponseWriter, req *http.Req
 	res.Write(dat)
 }
 
+// createChirp stores a new chirp and lets everything that follows new
+// chirps know about it. Both the API and the web UI post through it.
+func (cfg *apiConfig) createChirp(req *http.Request, userId uuid.UUID, body string) (JsonChirp, error) {
+	chirp, err := cfg.DB.CreateChirp(req.Context(), database.CreateChirpParams{
+		Body:   body,
+		UserID: userId,
+	})
+	if err != nil {
+		return JsonChirp{}, err
+	}
+	jsonChirp := jsonChirpFromDB(chirp)
+	cfg.publishChirpEvent(req, eventbus.ChirpCreated, jsonChirp)
+	cfg.federateChirp(req, activitypub.TypeCreate, jsonChirp)
+	go cfg.fetchLinkPreview(chirp.ID, chirp.Body)
+	return jsonChirp, nil
+}
+
 func (cfg *apiConfig) ChirpHandler(res http.ResponseWriter, req *http.Request) {
 	ChirpReqBody := struct {
 		Body string `json:"body"`
 	}{}
-	token, err := auth.GetBearerToken(req.Header)
+	userId, err := cfg.authenticate(req, scopeChirpsWrite)
 	if err != nil {
-		respondWithError(res, 401, err.Error())
+		respondToAuthError(res, err)
+		return
+	}
+	if !cfg.requireVerifiedEmail(res, req, userId, verifiedForChirps) {
 		return
 	}
 
@@ -414,26 +829,11 @@ func (cfg *apiConfig) ChirpHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.JwtToken)
-	if err != nil {
-		respondWithError(res, 401, err.Error())
-		return
-	}
-	Chirp, err := cfg.DB.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   ChirpReqBody.Body,
-		UserID: userId,
-	})
+	ChirpResBody, err := cfg.createChirp(req, userId, ChirpReqBody.Body)
 	if err != nil {
 		respondWithError(res, 500, err.Error())
 		return
 	}
-	ChirpResBody := JsonChirp{
-		ID:        Chirp.ID,
-		CreatedAt: Chirp.CreatedAt,
-		UpdatedAt: Chirp.UpdatedAt,
-		Body:      Chirp.Body,
-		UserID:    Chirp.UserID,
-	}
 
 	dat, err := json.Marshal(ChirpResBody)
 	if err != nil {
@@ -454,6 +854,10 @@ func (cfg *apiConfig) CreateUserHandler(res http.ResponseWriter, req *http.Reque
 		respondWithError(res, 500, err.Error())
 		return
 	}
+	if !validEmail(UserEmail.Email) {
+		respondWithError(res, 400, "invalid email")
+		return
+	}
 	hashed_password, err := auth.HashPassword(UserEmail.Password)
 	if err != nil {
 		respondWithError(res, 500, fmt.Sprintf("error in Hashing password: %v", err))
@@ -469,19 +873,22 @@ func (cfg *apiConfig) CreateUserHandler(res http.ResponseWriter, req *http.Reque
 		respondWithError(res, 500, err.Error())
 		return
 	}
+	cfg.sendVerificationEmail(user.ID, user.Email)
 
 	JsonUser := struct {
-		ID          uuid.UUID `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		EmailVerified bool      `json:"email_verified"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
 	}{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		IsChirpyRed:   user.IsChirpyRed,
 	}
 	dat, err := json.Marshal(JsonUser)
 	if err != nil {
@@ -583,6 +990,17 @@ func respondWithJson(w http.ResponseWriter, code int, payload string) {
 
 }
 
+func respondWithPayload(w http.ResponseWriter, code int, payload interface{}) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(dat)
+}
+
 func replaceProfane(body string) string {
 	words_array := strings.Split(body, " ")
 	for idx, word := range words_array {
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// notification types, kept in sync with the notification_type check
+// constraint on the notifications table
+const (
+	notificationFollow         = "follow"
+	notificationFollowRequest  = "follow_request"
+	notificationFollowApproved = "follow_approved"
+	notificationChirpyRed      = "chirpy_red"
+)
+
+type JsonNotification struct {
+	ID         uuid.UUID  `json:"id"`
+	Type       string     `json:"type"`
+	ActorID    *uuid.UUID `json:"actor_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	ActorCount int32      `json:"actor_count"`
+	Cursor     string     `json:"cursor"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Read       bool       `json:"read"`
+}
+
+func jsonNotificationFromDB(n database.Notification) JsonNotification {
+	notification := JsonNotification{
+		ID:         n.ID,
+		Type:       n.Type,
+		ActorCount: n.ActorCount,
+		Cursor:     strconv.FormatInt(n.Seq, 10),
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+		Read:       n.ReadAt.Valid,
+	}
+	if n.ActorID.Valid {
+		notification.ActorID = &n.ActorID.UUID
+	}
+	if n.ChirpID.Valid {
+		notification.ChirpID = &n.ChirpID.UUID
+	}
+	return notification
+}
+
+// GetNotificationsHandler pages through the caller's notifications, newest
+// first. Pass the returned next_cursor as ?cursor= to fetch the next page.
+func (cfg *apiConfig) GetNotificationsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeNotificationsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	before, limit, err := parsePage(req)
+	if err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	notifications, err := cfg.DB.GetNotifications(req.Context(), database.GetNotificationsParams{
+		UserID:   userId,
+		Before:   before,
+		MaxItems: int32(limit),
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	unread, err := cfg.DB.CountUnreadNotifications(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := struct {
+		Notifications []JsonNotification `json:"notifications"`
+		NextCursor    string             `json:"next_cursor"`
+		UnreadCount   int64              `json:"unread_count"`
+	}{
+		Notifications: []JsonNotification{},
+		UnreadCount:   unread,
+	}
+	for _, n := range notifications {
+		ResBody.Notifications = append(ResBody.Notifications, jsonNotificationFromDB(n))
+	}
+	if len(notifications) == limit {
+		ResBody.NextCursor = ResBody.Notifications[len(notifications)-1].Cursor
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+// MarkNotificationsReadHandler marks every notification up to and including
+// the given cursor as read. Without a cursor everything is marked read.
+func (cfg *apiConfig) MarkNotificationsReadHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeNotificationsWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	ReqBody := struct {
+		Cursor string `json:"cursor"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	upTo, err := parseCursor(ReqBody.Cursor)
+	if err != nil {
+		respondWithError(res, 400, "invalid cursor")
+		return
+	}
+	_, err = cfg.DB.MarkNotificationsRead(req.Context(), database.MarkNotificationsReadParams{
+		UserID: userId,
+		UpTo:   upTo,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
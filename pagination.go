@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parseCursor turns a cursor handed out by a paginated endpoint back into
+// a sequence number. An empty cursor means "from the newest item".
+func parseCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return math.MaxInt64, nil
+	}
+	return strconv.ParseInt(cursor, 10, 64)
+}
+
+// parsePage reads the ?cursor= and ?limit= query parameters shared by the
+// cursor paginated endpoints
+func parsePage(req *http.Request) (int64, int, error) {
+	before, err := parseCursor(req.URL.Query().Get("cursor"))
+	if err != nil {
+		return 0, 0, errors.New("invalid cursor")
+	}
+	limit := defaultPageSize
+	if l := req.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return 0, 0, errors.New("invalid limit")
+		}
+		limit = min(limit, maxPageSize)
+	}
+	return before, limit, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/mail"
+)
+
+const passwordResetTTL = time.Hour
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// requestPasswordReset emails a reset link if email belongs to an account.
+// It runs after the response is sent, so neither the response nor its
+// timing tell whether the account exists.
+func (cfg *apiConfig) requestPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	user, err := cfg.DB.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("error looking up %s for a password reset: %v", email, err)
+		return
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("error generating a password reset token: %v", err)
+		return
+	}
+	if err := cfg.DB.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetTTL),
+	}); err != nil {
+		log.Printf("error storing a password reset token: %v", err)
+		return
+	}
+	if err := cfg.DB.DeleteExpiredPasswordResetTokens(ctx); err != nil {
+		log.Printf("error deleting expired password reset tokens: %v", err)
+	}
+	link := cfg.BaseURL + "/app/password/reset?token=" + url.QueryEscape(token)
+	cfg.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new password, open this link within the next hour:\n\n%s\n\n"+
+			"Or send this token to /api/password/reset:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email and your password stays the same.\n", link, token),
+	})
+}
+
+// resetPassword sets a new password with a reset token. Every refresh
+// token of the user is revoked, so whoever knew the old password loses
+// their sessions.
+func (cfg *apiConfig) resetPassword(ctx context.Context, token, password string) error {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return cfg.withTx(ctx, func(q *database.Queries) error {
+		userId, err := q.UsePasswordResetToken(ctx, auth.HashToken(token))
+		if err == sql.ErrNoRows {
+			return errInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		if err := q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+			HashedPassword: hashedPassword,
+			ID:             userId,
+		}); err != nil {
+			return err
+		}
+		if err := q.DeleteUserPasswordResetTokens(ctx, userId); err != nil {
+			return err
+		}
+		return q.RevokeAllSessions(ctx, database.RevokeAllSessionsParams{
+			RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+			UserID:    userId,
+		})
+	})
+}
+
+// ForgotPasswordHandler always answers 202, whether or not an account has
+// the email
+func (cfg *apiConfig) ForgotPasswordHandler(res http.ResponseWriter, req *http.Request) {
+	ReqBody := struct {
+		Email string `json:"email"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	go cfg.requestPasswordReset(strings.TrimSpace(ReqBody.Email))
+	res.WriteHeader(202)
+}
+
+func (cfg *apiConfig) ResetPasswordHandler(res http.ResponseWriter, req *http.Request) {
+	ReqBody := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if ReqBody.Password == "" {
+		respondWithError(res, 400, "password is required")
+		return
+	}
+	err := cfg.resetPassword(req.Context(), ReqBody.Token, ReqBody.Password)
+	if err == errInvalidResetToken {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
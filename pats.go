@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	personalAccessTokensPerUser = 20
+	maxTokenNameLength          = 100
+	defaultTokenExpiryDays      = 30
+	maxTokenExpiryDays          = 365
+)
+
+// Scopes limit what a token may be used for. Every protected handler asks
+// for one when it authenticates the caller.
+const (
+	scopeChirpsRead         = "chirps:read"
+	scopeChirpsWrite        = "chirps:write"
+	scopeProfileWrite       = "profile:write"
+	scopeFollowsRead        = "follows:read"
+	scopeFollowsWrite       = "follows:write"
+	scopeListsRead          = "lists:read"
+	scopeListsWrite         = "lists:write"
+	scopeMessagesRead       = "messages:read"
+	scopeMessagesWrite      = "messages:write"
+	scopeNotificationsRead  = "notifications:read"
+	scopeNotificationsWrite = "notifications:write"
+	scopeWebhooksRead       = "webhooks:read"
+	scopeWebhooksWrite      = "webhooks:write"
+	// scopeAccount covers sessions and the tokens themselves. It is never
+	// granted to a personal access token, so only a full login has it.
+	scopeAccount = "account"
+)
+
+// grantableScopes are the scopes a personal access token can be given
+var grantableScopes = map[string]bool{
+	scopeChirpsRead:         true,
+	scopeChirpsWrite:        true,
+	scopeProfileWrite:       true,
+	scopeFollowsRead:        true,
+	scopeFollowsWrite:       true,
+	scopeListsRead:          true,
+	scopeListsWrite:         true,
+	scopeMessagesRead:       true,
+	scopeMessagesWrite:      true,
+	scopeNotificationsRead:  true,
+	scopeNotificationsWrite: true,
+	scopeWebhooksRead:       true,
+	scopeWebhooksWrite:      true,
+}
+
+var errInsufficientScope = errors.New("token lacks the scope for this request")
+
+// respondToAuthError answers a failed authenticate: 403 when the token is
+// fine but doesn't cover the request, 401 otherwise
+func respondToAuthError(res http.ResponseWriter, err error) {
+	if errors.Is(err, errInsufficientScope) {
+		respondWithError(res, 403, err.Error())
+		return
+	}
+	respondWithError(res, 401, err.Error())
+}
+
+type JsonPersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Token is only returned when the token is created
+	Token string `json:"token,omitempty"`
+}
+
+func jsonPersonalAccessTokenFromDB(pat database.PersonalAccessToken) JsonPersonalAccessToken {
+	token := JsonPersonalAccessToken{
+		ID:        pat.ID,
+		Name:      pat.Name,
+		Scopes:    pat.Scopes,
+		ExpiresAt: pat.ExpiresAt,
+		CreatedAt: pat.CreatedAt,
+	}
+	if pat.LastUsedAt.Valid {
+		token.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return token
+}
+
+// authenticatePAT looks a personal access token up by its digest and
+// checks it hasn't expired and grants scope
+func (cfg *apiConfig) authenticatePAT(ctx context.Context, token, scope string) (uuid.UUID, error) {
+	pat, err := cfg.DB.GetPersonalAccessTokenByHash(ctx, auth.HashToken(token))
+	if err == sql.ErrNoRows {
+		return uuid.UUID{}, errors.New("invalid token")
+	}
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if time.Now().UTC().After(pat.ExpiresAt) {
+		return uuid.UUID{}, errors.New("token expired")
+	}
+	if !slices.Contains(pat.Scopes, scope) {
+		return uuid.UUID{}, errInsufficientScope
+	}
+	if err := cfg.DB.TouchPersonalAccessToken(ctx, pat.ID); err != nil {
+		log.Printf("error recording use of personal access token %s: %v", pat.ID, err)
+	}
+	return pat.UserID, nil
+}
+
+// CreatePersonalAccessTokenHandler issues a token for scripts and bots.
+// The token itself is only shown in the response; just its digest is
+// stored.
+func (cfg *apiConfig) CreatePersonalAccessTokenHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	ReqBody := struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if ReqBody.Name == "" || len(ReqBody.Name) > maxTokenNameLength {
+		respondWithError(res, 400, "name must be between 1 and 100 characters")
+		return
+	}
+	scopes := []string{}
+	for _, scope := range ReqBody.Scopes {
+		if !grantableScopes[scope] {
+			respondWithError(res, 400, "unknown scope "+scope)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		respondWithError(res, 400, "at least one scope is required")
+		return
+	}
+	days := ReqBody.ExpiresInDays
+	if days == 0 {
+		days = defaultTokenExpiryDays
+	}
+	if days < 0 || days > maxTokenExpiryDays {
+		respondWithError(res, 400, "expires_in_days must be between 1 and 365")
+		return
+	}
+	existing, err := cfg.DB.GetUserPersonalAccessTokens(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if len(existing) >= personalAccessTokensPerUser {
+		respondWithError(res, 400, "too many personal access tokens")
+		return
+	}
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	pat, err := cfg.DB.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userId,
+		Name:      ReqBody.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().UTC().AddDate(0, 0, days),
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := jsonPersonalAccessTokenFromDB(pat)
+	ResBody.Token = token
+	respondWithPayload(res, 201, ResBody)
+}
+
+func (cfg *apiConfig) GetPersonalAccessTokensHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	pats, err := cfg.DB.GetUserPersonalAccessTokens(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonPersonalAccessToken{}
+	for _, pat := range pats {
+		ResBody = append(ResBody, jsonPersonalAccessTokenFromDB(pat))
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+func (cfg *apiConfig) DeletePersonalAccessTokenHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	tokenId, err := uuid.Parse(req.PathValue("tokenID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.DeletePersonalAccessToken(req.Context(), database.DeletePersonalAccessTokenParams{
+		ID:     tokenId,
+		UserID: userId,
+	})
+	respondToRowChange(res, result, err)
+}
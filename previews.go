@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/preview"
+	"github.com/google/uuid"
+)
+
+const (
+	previewConcurrency = 8
+	previewTimeout     = 15 * time.Second
+	// cached previews are reused for previewTTL, failed fetches are
+	// retried after previewRetryAfter
+	previewTTL        = 24 * time.Hour
+	previewRetryAfter = time.Hour
+)
+
+type JsonLinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// fetchLinkPreview looks up a preview for the first link in a new chirp
+// and attaches it. It runs in the background after the chirp is created,
+// so a preview shows up on later reads rather than in the create
+// response.
+func (cfg *apiConfig) fetchLinkPreview(chirpId uuid.UUID, body string) {
+	link := preview.FirstURL(body)
+	if link == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+
+	cached, err := cfg.DB.GetLinkPreview(ctx, link)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("error loading link preview for %s: %v", link, err)
+		return
+	}
+	fresh := err == nil &&
+		((cached.Ok && time.Since(cached.FetchedAt) < previewTTL) ||
+			(!cached.Ok && time.Since(cached.FetchedAt) < previewRetryAfter))
+	if !fresh {
+		p, fetchErr := cfg.Previews.Fetch(ctx, link)
+		cached = database.LinkPreview{
+			Url:         link,
+			Ok:          fetchErr == nil,
+			Title:       p.Title,
+			Description: p.Description,
+			ImageUrl:    p.ImageURL,
+			SiteName:    p.SiteName,
+		}
+		if err := cfg.DB.UpsertLinkPreview(ctx, database.UpsertLinkPreviewParams{
+			Url:         cached.Url,
+			Ok:          cached.Ok,
+			Title:       cached.Title,
+			Description: cached.Description,
+			ImageUrl:    cached.ImageUrl,
+			SiteName:    cached.SiteName,
+		}); err != nil {
+			log.Printf("error storing link preview for %s: %v", link, err)
+			return
+		}
+	}
+	if !cached.Ok {
+		return
+	}
+	if err := cfg.DB.SetChirpLinkPreview(ctx, database.SetChirpLinkPreviewParams{
+		Url:     link,
+		ChirpID: chirpId,
+	}); err != nil {
+		log.Printf("error attaching link preview to chirp %s: %v", chirpId, err)
+	}
+}
+
+// withPreviews fills in the link previews of chirps with one query
+func (cfg *apiConfig) withPreviews(ctx context.Context, chirps []JsonChirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	rows, err := cfg.DB.GetChirpLinkPreviews(ctx, ids)
+	if err != nil {
+		return err
+	}
+	previews := map[uuid.UUID]*JsonLinkPreview{}
+	for _, row := range rows {
+		previews[row.ChirpID] = &JsonLinkPreview{
+			URL:         row.Url,
+			Title:       row.Title,
+			Description: row.Description,
+			ImageURL:    row.ImageUrl,
+			SiteName:    row.SiteName,
+		}
+	}
+	for i := range chirps {
+		chirps[i].Preview = previews[chirps[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength caps what is stored from the User-Agent header
+const maxUserAgentLength = 512
+
+type JsonSession struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+}
+
+func jsonSessionFromDB(session database.Session) JsonSession {
+	return JsonSession{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		UserAgent:  session.UserAgent,
+		IP:         session.Ip,
+	}
+}
+
+// clientIP is the address the request came from. Forwarding headers are
+// ignored since anyone can set them.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func sessionUserAgent(req *http.Request) string {
+	userAgent := req.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return userAgent
+}
+
+// startSession records a new login for userId and returns its first
+// refresh token
+func (cfg *apiConfig) startSession(req *http.Request, userId uuid.UUID) (string, error) {
+	ctx := req.Context()
+	now := time.Now().UTC()
+	var token string
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		session, err := q.CreateSession(ctx, database.CreateSessionParams{
+			ID:         uuid.New(),
+			UserID:     userId,
+			CreatedAt:  now,
+			LastUsedAt: now,
+			UserAgent:  sessionUserAgent(req),
+			Ip:         clientIP(req),
+		})
+		if err != nil {
+			return err
+		}
+		token, err = cfg.issueRefreshToken(ctx, q, userId, session.ID, "")
+		return err
+	})
+	return token, err
+}
+
+// GetSessionsHandler lists the caller's logins that can still be
+// refreshed, most recently used first
+func (cfg *apiConfig) GetSessionsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	sessions, err := cfg.DB.GetActiveSessions(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonSession{}
+	for _, session := range sessions {
+		ResBody = append(ResBody, jsonSessionFromDB(session))
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+// RevokeSessionHandler logs one of the caller's sessions out. Access
+// tokens it already handed out stay valid until they expire.
+func (cfg *apiConfig) RevokeSessionHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	sessionId, err := uuid.Parse(req.PathValue("sessionID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.RevokeSession(req.Context(), database.RevokeSessionParams{
+		RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		FamilyID:  sessionId,
+		UserID:    userId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+// RevokeAllSessionsHandler logs the caller out everywhere, including the
+// session making the request
+func (cfg *apiConfig) RevokeAllSessionsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	if err := cfg.DB.RevokeAllSessions(req.Context(), database.RevokeAllSessionsParams{
+		RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		UserID:    userId,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
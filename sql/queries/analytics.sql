@@ -0,0 +1,17 @@
+-- name: AddChirpImpressions :exec
+-- chirps deleted since the impressions were counted drop out of the join
+INSERT INTO chirp_impressions(chirp_id, hour, impressions)
+SELECT batch.chirp_id, sqlc.arg(hour)::TIMESTAMP, batch.impressions
+FROM unnest(sqlc.arg(chirp_ids)::UUID[], sqlc.arg(counts)::BIGINT[]) AS batch(chirp_id, impressions)
+    JOIN chirps ON chirps.id = batch.chirp_id
+ON CONFLICT (chirp_id, hour) DO UPDATE
+    SET impressions = chirp_impressions.impressions + EXCLUDED.impressions;
+
+-- name: GetChirpImpressionsSince :many
+SELECT hour, impressions FROM chirp_impressions
+    WHERE chirp_id = $1 AND hour >= $2
+    ORDER BY hour;
+
+-- name: GetChirpImpressionTotal :one
+SELECT COALESCE(SUM(impressions), 0)::BIGINT AS total FROM chirp_impressions
+    WHERE chirp_id = $1;
@@ -0,0 +1,28 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks(blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execresult
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlocks :many
+SELECT * FROM blocks
+    WHERE blocker_id = $1
+    ORDER BY created_at;
+
+-- name: IsBlockedByAny :one
+-- reports whether any of blocker_ids has blocked blocked_id
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+        WHERE blocked_id = sqlc.arg(blocked_id) AND blocker_id = ANY(sqlc.arg(blocker_ids)::UUID[])
+);
+
+-- name: IsBlockedInConversation :one
+-- reports whether another participant of the conversation has blocked user_id
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+        JOIN conversation_participants ON conversation_participants.user_id = blocks.blocker_id
+        WHERE conversation_participants.conversation_id = sqlc.arg(conversation_id)
+            AND blocks.blocked_id = sqlc.arg(user_id)
+);
@@ -10,8 +10,67 @@ RETURNING *;
 -- name: GetAllChirps :many
 SELECT * FROM chirps ORDER BY created_at;
 
+-- name: GetVisibleChirps :many
+SELECT chirps.* FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE NOT users.is_protected
+        OR chirps.user_id = sqlc.arg(viewer_id)
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)
+                AND follows.followee_id = chirps.user_id
+                AND follows.status = 'approved'
+        )
+    ORDER BY chirps.created_at;
+
+-- name: GetVisibleChirpById :one
+SELECT chirps.* FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE chirps.id = sqlc.arg(id)
+        AND (
+            NOT users.is_protected
+            OR chirps.user_id = sqlc.arg(viewer_id)
+            OR EXISTS (
+                SELECT 1 FROM follows
+                WHERE follows.follower_id = sqlc.arg(viewer_id)
+                    AND follows.followee_id = chirps.user_id
+                    AND follows.status = 'approved'
+            )
+        );
+
 -- name: GetChirpById :one
 SELECT * FROM chirps WHERE id = $1;
 
 -- name: DeleteChirpById :exec
-DELETE FROM chirps WHERE id = $1;
\ No newline at end of file
+DELETE FROM chirps WHERE id = $1;
+
+-- name: GetRecentChirpsByUser :many
+SELECT * FROM chirps
+    WHERE user_id = $1
+    ORDER BY created_at DESC
+    LIMIT $2;
+
+-- name: GetRecentPublicChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE NOT users.is_protected
+        AND EXISTS (
+            SELECT 1 FROM regexp_matches(chirps.body, '#(\w+)', 'g') AS m
+            WHERE lower(m[1]) = lower(sqlc.arg(tag))
+        )
+    ORDER BY chirps.created_at DESC
+    LIMIT sqlc.arg(max_items);
+
+-- name: GetRecentVisibleChirps :many
+SELECT chirps.* FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE NOT users.is_protected
+        OR chirps.user_id = sqlc.arg(viewer_id)
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)
+                AND follows.followee_id = chirps.user_id
+                AND follows.status = 'approved'
+        )
+    ORDER BY chirps.created_at DESC
+    LIMIT sqlc.arg(max_items);
@@ -0,0 +1,70 @@
+-- name: CreateConversation :one
+-- returns no row if a direct conversation with this direct_key exists
+INSERT INTO conversations(id, direct_key, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), $1, NOW(), NOW()
+)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants(conversation_id, user_id, joined_at)
+VALUES (
+    $1, $2, NOW()
+);
+
+-- name: GetConversationParticipant :one
+SELECT * FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationParticipantIds :many
+SELECT user_id FROM conversation_participants
+    WHERE conversation_id = $1
+    ORDER BY joined_at, user_id;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations WHERE direct_key = $1;
+
+-- name: GetUserConversations :many
+SELECT conversations.id, conversations.created_at, conversations.updated_at,
+    conversation_participants.last_read_seq,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+            AND messages.seq > conversation_participants.last_read_seq
+            AND messages.sender_id <> conversation_participants.user_id
+    ) AS unread_count
+FROM conversations
+    JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+    WHERE conversation_participants.user_id = $1
+    ORDER BY conversations.updated_at DESC;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+    SET updated_at = NOW()
+    WHERE id = $1;
+
+-- name: CreateMessage :one
+INSERT INTO messages(id, conversation_id, sender_id, body, created_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, NOW()
+)
+RETURNING *;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+    WHERE conversation_id = sqlc.arg(conversation_id) AND seq < sqlc.arg(before)
+    ORDER BY seq DESC
+    LIMIT sqlc.arg(max_items);
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+    SET last_read_seq = GREATEST(
+        last_read_seq,
+        LEAST(
+            sqlc.arg(up_to)::bigint,
+            (SELECT COALESCE(MAX(seq), 0) FROM messages WHERE messages.conversation_id = sqlc.arg(conversation_id))
+        )
+    )
+    WHERE conversation_participants.conversation_id = sqlc.arg(conversation_id)
+        AND conversation_participants.user_id = sqlc.arg(user_id);
@@ -0,0 +1,11 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens(token_hash, user_id, email, expires_at, created_at)
+VALUES ($1, $2, $3, $4, NOW());
+
+-- name: DeleteExpiredEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens WHERE expires_at < NOW();
+
+-- name: UseEmailVerificationToken :one
+DELETE FROM email_verification_tokens
+    WHERE token_hash = $1 AND expires_at > NOW()
+RETURNING user_id, email;
@@ -0,0 +1,40 @@
+-- name: AcceptRemoteFollow :execresult
+UPDATE remote_follows SET accepted = TRUE
+    WHERE user_id = $1 AND actor_id = $2;
+
+-- name: CreateRemoteFollow :one
+INSERT INTO remote_follows(user_id, actor_id, inbox, created_at)
+VALUES (
+    $1, $2, $3, NOW()
+)
+ON CONFLICT (user_id, actor_id) DO UPDATE
+    SET inbox = EXCLUDED.inbox
+RETURNING *;
+
+-- name: CreateRemoteFollower :exec
+INSERT INTO remote_followers(user_id, actor_id, inbox, created_at)
+VALUES (
+    $1, $2, $3, NOW()
+)
+ON CONFLICT (user_id, actor_id) DO UPDATE
+    SET inbox = EXCLUDED.inbox;
+
+-- name: CreateUserKey :one
+-- concurrent first uses race to create the key, the loser gets the
+-- winner's row back
+INSERT INTO user_keys(user_id, public_key_pem, private_key_pem, created_at)
+VALUES (
+    $1, $2, $3, NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+    SET user_id = user_keys.user_id
+RETURNING *;
+
+-- name: DeleteRemoteFollower :exec
+DELETE FROM remote_followers WHERE user_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT DISTINCT inbox FROM remote_followers WHERE user_id = $1;
+
+-- name: GetUserKey :one
+SELECT * FROM user_keys WHERE user_id = $1;
@@ -0,0 +1,35 @@
+-- name: CreateFollow :one
+INSERT INTO follows(follower_id, followee_id, status, created_at, updated_at)
+VALUES (
+    $1, $2, $3, NOW(), NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO UPDATE
+    SET updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteFollow :execresult
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetPendingFollowRequests :many
+SELECT * FROM follows
+    WHERE followee_id = $1 AND status = 'pending'
+    ORDER BY created_at;
+
+-- name: ApproveFollowRequest :execresult
+UPDATE follows
+    SET status = 'approved', updated_at = NOW()
+    WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: DenyFollowRequest :execresult
+DELETE FROM follows
+    WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: ApproveAllFollowRequests :many
+UPDATE follows
+    SET status = 'approved', updated_at = NOW()
+    WHERE followee_id = $1 AND status = 'pending'
+RETURNING follower_id;
+
+-- name: GetApprovedFolloweeIds :many
+SELECT followee_id FROM follows
+    WHERE follower_id = $1 AND status = 'approved';
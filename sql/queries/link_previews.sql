@@ -0,0 +1,30 @@
+-- name: GetChirpLinkPreviews :many
+SELECT chirp_link_previews.chirp_id, link_previews.url, link_previews.title,
+    link_previews.description, link_previews.image_url, link_previews.site_name
+FROM chirp_link_previews
+    JOIN link_previews ON link_previews.url = chirp_link_previews.url
+    WHERE chirp_link_previews.chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+        AND link_previews.ok;
+
+-- name: GetLinkPreview :one
+SELECT * FROM link_previews WHERE url = $1;
+
+-- name: SetChirpLinkPreview :exec
+-- the chirp may have been deleted while its link was being fetched
+INSERT INTO chirp_link_previews(chirp_id, url)
+SELECT id, sqlc.arg(url) FROM chirps WHERE id = sqlc.arg(chirp_id)
+ON CONFLICT (chirp_id) DO UPDATE
+    SET url = EXCLUDED.url;
+
+-- name: UpsertLinkPreview :exec
+INSERT INTO link_previews(url, ok, title, description, image_url, site_name, fetched_at)
+VALUES (
+    $1, $2, $3, $4, $5, $6, NOW()
+)
+ON CONFLICT (url) DO UPDATE SET
+    ok = EXCLUDED.ok,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    image_url = EXCLUDED.image_url,
+    site_name = EXCLUDED.site_name,
+    fetched_at = EXCLUDED.fetched_at;
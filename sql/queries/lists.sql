@@ -0,0 +1,51 @@
+-- name: CreateList :one
+INSERT INTO lists(id, user_id, name, is_private, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, NOW(), NOW()
+)
+RETURNING *;
+
+-- name: GetListById :one
+SELECT * FROM lists WHERE id = $1;
+
+-- name: GetUserLists :many
+SELECT * FROM lists WHERE user_id = $1 ORDER BY created_at;
+
+-- name: UpdateList :one
+UPDATE lists
+    SET name = $1, is_private = $2, updated_at = NOW()
+    WHERE id = $3
+    RETURNING *;
+
+-- name: DeleteList :exec
+DELETE FROM lists WHERE id = $1;
+
+-- name: AddListMember :exec
+INSERT INTO list_members(list_id, user_id, created_at)
+VALUES (
+    $1, $2, NOW()
+)
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: RemoveListMember :execresult
+DELETE FROM list_members WHERE list_id = $1 AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT * FROM list_members WHERE list_id = $1 ORDER BY created_at;
+
+-- name: GetListChirps :many
+SELECT chirps.* FROM chirps
+    JOIN list_members ON list_members.user_id = chirps.user_id
+    JOIN users ON users.id = chirps.user_id
+    WHERE list_members.list_id = sqlc.arg(list_id)
+        AND (
+            NOT users.is_protected
+            OR chirps.user_id = sqlc.arg(viewer_id)
+            OR EXISTS (
+                SELECT 1 FROM follows
+                WHERE follows.follower_id = sqlc.arg(viewer_id)
+                    AND follows.followee_id = chirps.user_id
+                    AND follows.status = 'approved'
+            )
+        )
+    ORDER BY chirps.created_at;
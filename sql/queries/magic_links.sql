@@ -0,0 +1,22 @@
+-- name: CountMagicLinkRequests :one
+SELECT COUNT(*) FROM magic_link_requests
+    WHERE email = $1 AND created_at > $2;
+
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links(token_hash, user_id, nonce_hash, expires_at, email, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW());
+
+-- name: CreateMagicLinkRequest :exec
+INSERT INTO magic_link_requests(email, created_at)
+VALUES ($1, NOW());
+
+-- name: DeleteExpiredMagicLinks :exec
+DELETE FROM magic_links WHERE expires_at < NOW();
+
+-- name: DeleteMagicLinkRequestsBefore :exec
+DELETE FROM magic_link_requests WHERE created_at < $1;
+
+-- name: UseMagicLink :one
+DELETE FROM magic_links
+    WHERE token_hash = $1 AND nonce_hash = $2 AND expires_at > NOW()
+RETURNING user_id, email;
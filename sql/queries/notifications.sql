@@ -0,0 +1,21 @@
+-- name: CreateNotification :one
+INSERT INTO notifications(id, user_id, type, actor_id, chirp_id, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW()
+)
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+    WHERE user_id = sqlc.arg(user_id) AND seq < sqlc.arg(before)
+    ORDER BY seq DESC
+    LIMIT sqlc.arg(max_items);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+    WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execresult
+UPDATE notifications
+    SET read_at = NOW()
+    WHERE user_id = sqlc.arg(user_id) AND seq <= sqlc.arg(up_to) AND read_at IS NULL;
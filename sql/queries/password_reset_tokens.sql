@@ -0,0 +1,15 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens(token_hash, user_id, expires_at, created_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: DeleteExpiredPasswordResetTokens :exec
+DELETE FROM password_reset_tokens WHERE expires_at < NOW();
+
+-- name: DeleteUserPasswordResetTokens :exec
+DELETE FROM password_reset_tokens WHERE user_id = $1;
+
+-- name: UsePasswordResetToken :one
+-- marks the token used and returns its user, if it was unused and unexpired
+UPDATE password_reset_tokens SET used_at = NOW()
+    WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING user_id;
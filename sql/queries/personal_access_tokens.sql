@@ -0,0 +1,22 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens(id, user_id, name, token_hash, scopes, expires_at, created_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, $5, NOW()
+)
+RETURNING *;
+
+-- name: DeletePersonalAccessToken :execresult
+DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2;
+
+-- name: GetPersonalAccessTokenByHash :one
+SELECT * FROM personal_access_tokens WHERE token_hash = $1;
+
+-- name: GetUserPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at;
+
+-- name: TouchPersonalAccessToken :exec
+-- last_used_at is only kept to the minute, which saves a write on most
+-- requests
+UPDATE personal_access_tokens SET last_used_at = NOW()
+    WHERE id = $1
+        AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
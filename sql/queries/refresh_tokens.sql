@@ -1,12 +1,28 @@
 -- name: CreateRefereshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, expires_at, user_id)
+INSERT INTO refresh_tokens(token_hash, created_at, updated_at, expires_at, user_id, family_id, parent_hash)
 VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6, $7
 ) RETURNING *;
 
 -- name: GetUserFromRefreshToken :one
-SELECT * FROM refresh_tokens WHERE token = $1;
+SELECT * FROM refresh_tokens WHERE token_hash = $1;
 
 -- name: RevokeToken :exec
+-- revokes every token in the family of the given token
 UPDATE refresh_tokens
-    SET revoked_at = $1 WHERE token = $2;
\ No newline at end of file
+    SET revoked_at = $1, updated_at = $1
+    WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $2)
+        AND revoked_at IS NULL;
+
+-- name: RotateRefreshToken :execresult
+-- marks a token as used up. It affects no rows when the token was already
+-- rotated or revoked, which makes concurrent refreshes with the same token
+-- count as reuse.
+UPDATE refresh_tokens
+    SET rotated_at = $1, updated_at = $1
+    WHERE token_hash = $2 AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+    SET revoked_at = $1, updated_at = $1
+    WHERE family_id = $2 AND revoked_at IS NULL;
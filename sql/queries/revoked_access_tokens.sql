@@ -0,0 +1,12 @@
+-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens WHERE expires_at < NOW();
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1 FROM revoked_access_tokens WHERE jti = $1
+);
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens(jti, expires_at, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (jti) DO NOTHING;
@@ -0,0 +1,33 @@
+-- name: CreateSession :one
+INSERT INTO sessions(id, user_id, created_at, last_used_at, user_agent, ip)
+VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetActiveSessions :many
+-- sessions that still hold a usable refresh token
+SELECT * FROM sessions
+    WHERE user_id = $1
+        AND EXISTS (
+            SELECT 1 FROM refresh_tokens
+            WHERE refresh_tokens.family_id = sessions.id
+                AND refresh_tokens.revoked_at IS NULL
+                AND refresh_tokens.rotated_at IS NULL
+                AND refresh_tokens.expires_at > NOW()
+        )
+    ORDER BY last_used_at DESC;
+
+-- name: RevokeAllSessions :exec
+UPDATE refresh_tokens
+    SET revoked_at = $1, updated_at = $1
+    WHERE user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeSession :execresult
+UPDATE refresh_tokens
+    SET revoked_at = $1, updated_at = $1
+    WHERE family_id = $2 AND user_id = $3 AND revoked_at IS NULL;
+
+-- name: TouchSession :exec
+UPDATE sessions
+    SET last_used_at = $1, user_agent = $2, ip = $3
+    WHERE id = $4;
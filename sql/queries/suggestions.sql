@@ -0,0 +1,71 @@
+-- name: GetAllUserIds :many
+SELECT id FROM users ORDER BY id;
+
+-- name: GetFriendsOfFriends :many
+SELECT theirs.followee_id AS candidate_id, COUNT(*) AS mutual_follows
+FROM follows mine
+    JOIN follows theirs ON theirs.follower_id = mine.followee_id
+    WHERE mine.follower_id = sqlc.arg(user_id)
+        AND mine.status = 'approved'
+        AND theirs.status = 'approved'
+        AND theirs.followee_id <> sqlc.arg(user_id)
+        AND NOT EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(user_id)
+                AND follows.followee_id = theirs.followee_id
+        )
+    GROUP BY theirs.followee_id;
+
+-- name: GetRecentChirpCounts :many
+SELECT user_id, COUNT(*) AS recent_chirps FROM chirps
+    WHERE created_at > NOW() - INTERVAL '7 days'
+    GROUP BY user_id;
+
+-- name: GetRecentHashtags :many
+-- every account's distinct hashtags from the last 30 days, read once per
+-- run of the suggestions job
+SELECT DISTINCT chirps.user_id, lower(m[1]) AS tag, users.is_protected
+FROM chirps
+    JOIN users ON users.id = chirps.user_id,
+    regexp_matches(chirps.body, '#(\w+)', 'g') AS m
+    WHERE chirps.created_at > NOW() - INTERVAL '30 days';
+
+-- name: GetSuggestionExclusions :many
+-- accounts never suggested to user_id: those it follows or has asked to
+-- follow, those blocked either way, and suspended accounts
+SELECT followee_id AS excluded_id FROM follows WHERE follower_id = sqlc.arg(user_id)
+UNION
+SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id FROM blocks WHERE blocked_id = sqlc.arg(user_id)
+UNION
+SELECT suspensions.user_id FROM suspensions;
+
+-- name: DeleteUserSuggestions :exec
+DELETE FROM user_suggestions WHERE user_id = $1;
+
+-- name: CreateUserSuggestion :exec
+INSERT INTO user_suggestions(user_id, suggested_id, score, mutual_follows, shared_hashtags, recent_chirps, computed_at)
+VALUES (
+    $1, $2, $3, $4, $5, $6, NOW()
+);
+
+-- name: GetUserSuggestions :many
+SELECT user_suggestions.* FROM user_suggestions
+    WHERE user_suggestions.user_id = $1
+        AND NOT EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = user_suggestions.user_id
+                AND follows.followee_id = user_suggestions.suggested_id
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM blocks
+            WHERE (blocks.blocker_id = user_suggestions.user_id AND blocks.blocked_id = user_suggestions.suggested_id)
+                OR (blocks.blocker_id = user_suggestions.suggested_id AND blocks.blocked_id = user_suggestions.user_id)
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM suspensions
+            WHERE suspensions.user_id = user_suggestions.suggested_id
+        )
+    ORDER BY user_suggestions.score DESC
+    LIMIT $2;
@@ -0,0 +1,7 @@
+-- name: SuspendUser :exec
+INSERT INTO suspensions(user_id, created_at)
+VALUES ($1, NOW())
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: UnsuspendUser :execresult
+DELETE FROM suspensions WHERE user_id = $1;
@@ -0,0 +1,69 @@
+-- name: ConfirmTOTPSecret :exec
+UPDATE totp_secrets SET confirmed_at = NOW(), last_step = $2
+    WHERE user_id = $1;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges(token_hash, user_id, expires_at, created_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes(user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: DeleteExpiredLoginChallenges :exec
+DELETE FROM login_challenges WHERE expires_at < NOW();
+
+-- name: DeleteLoginChallenge :exec
+DELETE FROM login_challenges WHERE token_hash = $1;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: DeleteTOTPSecret :exec
+DELETE FROM totp_secrets WHERE user_id = $1;
+
+-- name: GetLoginChallenge :one
+SELECT * FROM login_challenges WHERE token_hash = $1;
+
+-- name: GetTOTPSecret :one
+SELECT * FROM totp_secrets WHERE user_id = $1;
+
+-- name: RecordLoginChallengeFailure :one
+UPDATE login_challenges SET attempts = attempts + 1
+    WHERE token_hash = $1
+RETURNING attempts;
+
+-- name: RecordTOTPFailure :exec
+-- once failed_attempts reaches max_failures every further wrong code locks
+-- the checks again, until a right one resets the count
+UPDATE totp_secrets
+    SET failed_attempts = failed_attempts + 1,
+        locked_until = CASE
+            WHEN failed_attempts + 1 >= sqlc.arg(max_failures)::integer THEN sqlc.arg(locked_until)::timestamp
+            ELSE locked_until
+        END
+    WHERE user_id = sqlc.arg(user_id);
+
+-- name: ResetTOTPFailures :exec
+UPDATE totp_secrets SET failed_attempts = 0, locked_until = NULL
+    WHERE user_id = $1;
+
+-- name: UpsertTOTPSecret :exec
+-- enrolling again replaces a secret that was never confirmed, but not one
+-- in use
+INSERT INTO totp_secrets(user_id, secret, last_step, created_at)
+VALUES ($1, $2, 0, NOW())
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at
+    WHERE totp_secrets.confirmed_at IS NULL;
+
+-- name: UseRecoveryCode :execresult
+UPDATE recovery_codes SET used_at = NOW()
+    WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: UseTOTPStep :execresult
+-- a time step is only accepted once, so a code can't be replayed
+UPDATE totp_secrets SET last_step = $2
+    WHERE user_id = $1 AND last_step < $2;
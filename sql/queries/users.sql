@@ -11,12 +11,36 @@ DELETE FROM users;
 SELECT * FROM users WHERE email = $1;
 
 -- name: UpdateUserById :one
+-- changing the email clears its verification
 UPDATE users
-    SET updated_at=$1, email=$2, hashed_password=$3
+    SET updated_at=$1, email=$2, hashed_password=$3,
+        email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
     WHERE id = $4
     RETURNING *;
 
+-- name: MarkEmailVerified :execresult
+-- only verifies the address the token was sent to, in case the email was
+-- changed since
+UPDATE users
+    SET email_verified_at=NOW(), updated_at=NOW()
+    WHERE id = $1 AND email = $2 AND email_verified_at IS NULL;
+
 -- name: MarkUserRed :execresult
+-- changes no row if the user doesn't exist or is already Red
 UPDATE users
     SET is_chirpy_red=true
-    WHERE id = $1; 
\ No newline at end of file
+    WHERE id = $1 AND NOT is_chirpy_red;
+
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: SetUserProtected :one
+UPDATE users
+    SET is_protected=$1, updated_at=NOW()
+    WHERE id = $2
+    RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+    SET hashed_password=$1, updated_at=NOW()
+    WHERE id = $2;
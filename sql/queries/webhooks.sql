@@ -0,0 +1,79 @@
+-- name: ClaimDueWebhookDeliveries :many
+-- pushes next_attempt_at out as a lease, so other instances skip the
+-- claimed rows while they are being sent
+WITH claimed AS (
+    UPDATE webhook_deliveries SET next_attempt_at = NOW() + INTERVAL '5 minutes'
+    WHERE webhook_deliveries.id IN (
+        SELECT webhook_deliveries.id FROM webhook_deliveries
+            JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+            WHERE webhook_deliveries.status = 'pending'
+                AND webhook_deliveries.next_attempt_at <= NOW()
+                AND webhooks.active
+            ORDER BY webhook_deliveries.next_attempt_at
+            LIMIT $1
+            FOR UPDATE OF webhook_deliveries SKIP LOCKED
+    )
+    RETURNING webhook_deliveries.*
+)
+SELECT claimed.id, claimed.webhook_id, claimed.event, claimed.payload, claimed.attempts,
+    webhooks.url, webhooks.secret
+FROM claimed JOIN webhooks ON webhooks.id = claimed.webhook_id;
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks(id, user_id, url, secret, events, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW()
+)
+RETURNING *;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries(id, webhook_id, event, payload, next_attempt_at, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, NOW(), NOW(), NOW()
+);
+
+-- name: DeleteWebhook :execresult
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: EnableWebhook :execresult
+UPDATE webhooks SET active = TRUE, consecutive_failures = 0, updated_at = NOW()
+    WHERE id = $1 AND user_id = $2;
+
+-- name: GetActiveWebhooksForEvent :many
+SELECT * FROM webhooks
+    WHERE user_id = sqlc.arg(user_id) AND active AND sqlc.arg(event)::TEXT = ANY(events);
+
+-- name: GetUserWebhooks :many
+SELECT * FROM webhooks WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetWebhookById :one
+SELECT * FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+    WHERE webhook_id = $1
+    ORDER BY created_at DESC
+    LIMIT $2;
+
+-- name: RecordWebhookFailure :one
+-- returns whether the webhook is still active after this failure
+UPDATE webhooks SET
+    consecutive_failures = consecutive_failures + 1,
+    active = consecutive_failures + 1 < sqlc.arg(max_failures)::INTEGER,
+    updated_at = NOW()
+    WHERE id = sqlc.arg(id)
+RETURNING active;
+
+-- name: RecordWebhookSuccess :exec
+UPDATE webhooks SET consecutive_failures = 0, updated_at = NOW()
+    WHERE id = $1 AND consecutive_failures > 0;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries SET
+    status = $2,
+    attempts = $3,
+    response_status = $4,
+    error = $5,
+    next_attempt_at = $6,
+    updated_at = NOW()
+    WHERE id = $1;
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD is_protected BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE follows(
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    status TEXT NOT NULL DEFAULT 'approved',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(follower_id, followee_id),
+    CONSTRAINT fk_follower FOREIGN KEY(follower_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_followee FOREIGN KEY(followee_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT follow_status CHECK (status IN ('pending', 'approved'))
+);
+
+-- +goose Down
+DROP TABLE follows;
+
+ALTER TABLE users
+DROP is_protected;
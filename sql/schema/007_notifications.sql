@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE notifications(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    actor_id UUID DEFAULT NULL,
+    chirp_id UUID DEFAULT NULL,
+    actor_count INTEGER NOT NULL DEFAULT 1,
+    seq BIGSERIAL NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP DEFAULT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_actors FOREIGN KEY(actor_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_chirps FOREIGN KEY(chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT notification_type CHECK (
+        type IN ('mention', 'reply', 'like', 'rechirp', 'follow', 'chirpy_red')
+    )
+);
+
+CREATE INDEX notifications_user_seq ON notifications(user_id, seq);
+
+-- unread likes on the same chirp collapse into a single row
+CREATE UNIQUE INDEX notifications_unread_likes ON notifications(user_id, type, chirp_id)
+    WHERE type = 'like' AND read_at IS NULL;
+
+-- +goose Down
+DROP TABLE notifications;
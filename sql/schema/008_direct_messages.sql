@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE conversations(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE conversation_participants(
+    conversation_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    last_read_seq BIGINT NOT NULL DEFAULT 0,
+    joined_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(conversation_id, user_id),
+    CONSTRAINT fk_conversations FOREIGN KEY(conversation_id)
+    REFERENCES conversations(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE messages(
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL,
+    sender_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    seq BIGSERIAL NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_conversations FOREIGN KEY(conversation_id)
+    REFERENCES conversations(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_users FOREIGN KEY(sender_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX messages_conversation_seq ON messages(conversation_id, seq);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE lists(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE list_members(
+    list_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(list_id, user_id),
+    CONSTRAINT fk_lists FOREIGN KEY(list_id)
+    REFERENCES lists(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
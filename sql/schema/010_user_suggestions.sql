@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE user_suggestions(
+    user_id UUID NOT NULL,
+    suggested_id UUID NOT NULL,
+    score INTEGER NOT NULL,
+    mutual_follows INTEGER NOT NULL,
+    shared_hashtags INTEGER NOT NULL,
+    recent_chirps INTEGER NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, suggested_id),
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_suggested FOREIGN KEY(suggested_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE user_suggestions;
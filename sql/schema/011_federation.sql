@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE user_keys(
+    user_id UUID PRIMARY KEY,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE remote_followers(
+    user_id UUID NOT NULL,
+    actor_id TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, actor_id),
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE remote_follows(
+    user_id UUID NOT NULL,
+    actor_id TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    accepted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, actor_id),
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE remote_follows;
+DROP TABLE remote_followers;
+DROP TABLE user_keys;
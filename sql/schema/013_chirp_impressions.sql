@@ -0,0 +1,14 @@
+-- +goose Up
+-- hourly rollups of chirp impressions, counted in memory and added in batches
+CREATE TABLE chirp_impressions(
+    chirp_id UUID NOT NULL,
+    hour TIMESTAMP NOT NULL,
+    impressions BIGINT NOT NULL,
+    PRIMARY KEY(chirp_id, hour),
+    CONSTRAINT fk_chirps FOREIGN KEY(chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_impressions;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- one row per URL, shared by every chirp linking to it. Failed fetches are
+-- kept too, with ok = FALSE, so dead links aren't fetched again and again.
+CREATE TABLE link_previews(
+    url TEXT PRIMARY KEY,
+    ok BOOLEAN NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    fetched_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chirp_link_previews(
+    chirp_id UUID PRIMARY KEY,
+    url TEXT NOT NULL,
+    CONSTRAINT fk_chirps FOREIGN KEY(chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_link_previews FOREIGN KEY(url)
+    REFERENCES link_previews(url)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_link_previews;
+DROP TABLE link_previews;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- every login starts a family of refresh tokens. Each refresh rotates the
+-- presented token into a child in the same family, and presenting an
+-- already rotated token revokes the whole family.
+ALTER TABLE refresh_tokens
+ADD family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD parent_token VARCHAR DEFAULT NULL,
+ADD rotated_at TIMESTAMP DEFAULT NULL,
+ADD CONSTRAINT fk_parent_token FOREIGN KEY(parent_token)
+    REFERENCES refresh_tokens(token)
+    ON DELETE SET NULL
+    ON UPDATE CASCADE;
+
+ALTER TABLE refresh_tokens
+ALTER family_id DROP DEFAULT;
+
+CREATE INDEX refresh_tokens_family ON refresh_tokens(family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family;
+
+ALTER TABLE refresh_tokens
+DROP family_id,
+DROP parent_token,
+DROP rotated_at;
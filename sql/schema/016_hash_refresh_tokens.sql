@@ -0,0 +1,23 @@
+-- +goose Up
+-- refresh tokens are stored as the hex SHA-256 digest auth.HashToken
+-- computes. Existing tokens keep working because clients still present
+-- the raw value, which is hashed before the lookup. parent_hash follows
+-- through ON UPDATE CASCADE.
+ALTER TABLE refresh_tokens
+RENAME token TO token_hash;
+
+ALTER TABLE refresh_tokens
+RENAME parent_token TO parent_hash;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- +goose Down
+-- digests can't be turned back into tokens, so going down ends every login
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME parent_hash TO parent_token;
+
+ALTER TABLE refresh_tokens
+RENAME token_hash TO token;
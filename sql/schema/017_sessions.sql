@@ -0,0 +1,32 @@
+-- +goose Up
+-- a session is one login, the family its refresh tokens rotate within.
+-- Its id is safe to show to clients, unlike the tokens.
+CREATE TABLE sessions(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user ON sessions(user_id, last_used_at);
+
+INSERT INTO sessions(id, user_id, created_at, last_used_at)
+SELECT family_id, user_id, MIN(created_at), MAX(updated_at)
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens
+ADD CONSTRAINT fk_sessions FOREIGN KEY(family_id)
+    REFERENCES sessions(id)
+    ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT fk_sessions;
+
+DROP TABLE sessions;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- access tokens revoked before they expire, by jti. Rows are only needed
+-- until the token would have expired anyway.
+CREATE TABLE revoked_access_tokens(
+    jti UUID PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_access_tokens_expiry ON revoked_access_tokens(expires_at);
+
+-- +goose Down
+DROP TABLE revoked_access_tokens;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- a user has two-factor authentication on once their secret is confirmed.
+-- last_step is the newest TOTP time step used, which keeps codes from
+-- being replayed.
+CREATE TABLE totp_secrets(
+    user_id UUID PRIMARY KEY,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMP DEFAULT NULL,
+    last_step BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes(
+    user_id UUID NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP DEFAULT NULL,
+    PRIMARY KEY (user_id, code_hash),
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- logins that passed the password check and wait for the second factor
+CREATE TABLE login_challenges(
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE login_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE totp_secrets;
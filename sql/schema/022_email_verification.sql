@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP DEFAULT NULL;
+
+-- accounts from before verification existed count as verified, so turning
+-- on REQUIRE_VERIFIED_EMAIL doesn't lock them out
+UPDATE users SET email_verified_at = NOW();
+
+-- a token verifies the email it was sent to, which may no longer be the
+-- user's by the time it is used
+CREATE TABLE email_verification_tokens(
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    email TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+ALTER TABLE users DROP COLUMN email_verified_at;
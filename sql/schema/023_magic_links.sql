@@ -0,0 +1,26 @@
+-- +goose Up
+-- a link only works in the browser that asked for it, which holds the
+-- nonce in a cookie
+CREATE TABLE magic_links(
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    nonce_hash TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- every request for a link, whether or not the email has an account, so
+-- the rate limit gives nothing away
+CREATE TABLE magic_link_requests(
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX magic_link_requests_email ON magic_link_requests(email, created_at);
+
+-- +goose Down
+DROP TABLE magic_link_requests;
+DROP TABLE magic_links;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- tells a requester that a protected account approved their follow request
+ALTER TABLE notifications DROP CONSTRAINT notification_type;
+ALTER TABLE notifications ADD CONSTRAINT notification_type CHECK (
+    type IN ('mention', 'reply', 'like', 'rechirp', 'follow', 'follow_approved', 'chirpy_red')
+);
+
+-- +goose Down
+DELETE FROM notifications WHERE type = 'follow_approved';
+ALTER TABLE notifications DROP CONSTRAINT notification_type;
+ALTER TABLE notifications ADD CONSTRAINT notification_type CHECK (
+    type IN ('mention', 'reply', 'like', 'rechirp', 'follow', 'chirpy_red')
+);
@@ -0,0 +1,26 @@
+-- +goose Up
+-- direct_key is set on two-person conversations to their participants'
+-- ids in order, so there is only ever one between the same pair
+ALTER TABLE conversations ADD COLUMN direct_key TEXT DEFAULT NULL;
+
+-- where a pair already has more than one, the oldest becomes theirs
+UPDATE conversations SET direct_key = pairs.direct_key
+FROM (
+    SELECT DISTINCT ON (direct.direct_key) direct.conversation_id, direct.direct_key
+    FROM (
+        SELECT conversation_participants.conversation_id,
+            MIN(conversation_participants.user_id::text) || ':' || MAX(conversation_participants.user_id::text) AS direct_key
+        FROM conversation_participants
+        GROUP BY conversation_participants.conversation_id
+        HAVING COUNT(*) = 2
+    ) AS direct
+        JOIN conversations ON conversations.id = direct.conversation_id
+    ORDER BY direct.direct_key, conversations.created_at
+) AS pairs
+WHERE conversations.id = pairs.conversation_id;
+
+CREATE UNIQUE INDEX conversations_direct_key ON conversations(direct_key);
+
+-- +goose Down
+DROP INDEX conversations_direct_key;
+ALTER TABLE conversations DROP COLUMN direct_key;
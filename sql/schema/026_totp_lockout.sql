@@ -0,0 +1,9 @@
+-- +goose Up
+-- wrong codes are counted per user, across login challenges and the web
+-- form, and lock two-factor checks for a while once there are too many
+ALTER TABLE totp_secrets ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE totp_secrets ADD COLUMN locked_until TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE totp_secrets DROP COLUMN locked_until;
+ALTER TABLE totp_secrets DROP COLUMN failed_attempts;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- chirps can't be liked, rechirped or replied to and users have no handles
+-- to mention, so only the types something actually sends are allowed. A
+-- protected account is told about follow requests waiting for approval.
+DROP INDEX notifications_unread_likes;
+DELETE FROM notifications WHERE type IN ('mention', 'reply', 'like', 'rechirp');
+ALTER TABLE notifications DROP CONSTRAINT notification_type;
+ALTER TABLE notifications ADD CONSTRAINT notification_type CHECK (
+    type IN ('follow', 'follow_request', 'follow_approved', 'chirpy_red')
+);
+
+-- +goose Down
+DELETE FROM notifications WHERE type = 'follow_request';
+ALTER TABLE notifications DROP CONSTRAINT notification_type;
+ALTER TABLE notifications ADD CONSTRAINT notification_type CHECK (
+    type IN ('mention', 'reply', 'like', 'rechirp', 'follow', 'follow_approved', 'chirpy_red')
+);
+CREATE UNIQUE INDEX notifications_unread_likes ON notifications(user_id, type, chirp_id)
+    WHERE type = 'like' AND read_at IS NULL;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE suspensions(
+    user_id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_users FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE suspensions;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- lets the newest chirps be read without sorting the whole table
+CREATE INDEX chirps_created_at ON chirps(created_at);
+
+-- +goose Down
+DROP INDEX chirps_created_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- the address a link was sent to, which only counts as verified if the
+-- account still has it when the link is used. Links already sent don't
+-- have one and expire within minutes anyway, so they are dropped.
+DELETE FROM magic_links;
+ALTER TABLE magic_links ADD COLUMN email TEXT NOT NULL;
+
+-- +goose Down
+ALTER TABLE magic_links DROP COLUMN email;
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	streamHistorySize = 1000
+	streamBufferSize  = 64
+	streamHeartbeat   = 15 * time.Second
+)
+
+// audience is what a live subscriber is allowed to see
+type audience struct {
+	viewerId uuid.UUID
+	// approved holds the viewer's approved followees, who are the only
+	// protected authors the viewer may see
+	approved map[uuid.UUID]bool
+}
+
+func (cfg *apiConfig) loadAudience(ctx context.Context, viewerId uuid.UUID) (audience, error) {
+	a := audience{viewerId: viewerId, approved: map[uuid.UUID]bool{}}
+	if viewerId == uuid.Nil {
+		return a, nil
+	}
+	followees, err := cfg.DB.GetApprovedFolloweeIds(ctx, viewerId)
+	if err != nil {
+		return a, err
+	}
+	for _, id := range followees {
+		a.approved[id] = true
+	}
+	return a, nil
+}
+
+// follows reports whether the author's chirps belong in the viewer's home
+// timeline, which includes the viewer's own
+func (a audience) follows(authorId uuid.UUID) bool {
+	return (a.viewerId != uuid.Nil && authorId == a.viewerId) || a.approved[authorId]
+}
+
+func (a audience) canSee(event stream.Event) bool {
+	if event.Type == stream.EventNotification {
+		return a.viewerId != uuid.Nil && event.RecipientID == a.viewerId
+	}
+	return !event.AuthorProtected || a.follows(event.AuthorID)
+}
+
+// streamFilter decides which published events an SSE subscriber sees
+type streamFilter struct {
+	audience
+	authorId uuid.UUID
+	homeOnly bool
+}
+
+func (f streamFilter) allows(event stream.Event) bool {
+	if event.Type == stream.EventNotification || !f.canSee(event) {
+		return false
+	}
+	if f.authorId != uuid.Nil && event.AuthorID != f.authorId {
+		return false
+	}
+	if f.homeOnly && !f.follows(event.AuthorID) {
+		return false
+	}
+	return true
+}
+
+// StreamHandler pushes new chirps and deletions over Server-Sent Events.
+// ?user_id= limits the stream to one author and ?feed=home to the caller
+// and the accounts they follow. Clients reconnecting with Last-Event-ID
+// get whatever they missed while it is still in the hub's history.
+func (cfg *apiConfig) StreamHandler(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		respondWithError(res, 500, "streaming unsupported")
+		return
+	}
+	viewer, err := cfg.loadAudience(req.Context(), cfg.viewerID(req, scopeChirpsRead))
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	filter := streamFilter{audience: viewer}
+	if authorParam := req.URL.Query().Get("user_id"); authorParam != "" {
+		authorId, err := uuid.Parse(authorParam)
+		if err != nil {
+			respondWithError(res, 400, "invalid user_id")
+			return
+		}
+		filter.authorId = authorId
+	}
+	if req.URL.Query().Get("feed") == "home" {
+		if filter.viewerId == uuid.Nil {
+			respondWithError(res, 401, "home feed requires authentication")
+			return
+		}
+		filter.homeOnly = true
+	}
+	sub, backlog := cfg.Stream.Subscribe(req.Header.Get("Last-Event-ID"))
+	defer cfg.Stream.Unsubscribe(sub)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(200)
+	flusher.Flush()
+
+	for _, event := range backlog {
+		if !writeStreamEvent(res, filter, event) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, open := <-sub.C:
+			if !open {
+				// dropped for falling behind, or the server is shutting
+				// down; the client reconnects with Last-Event-ID to catch up
+				return
+			}
+			if !writeStreamEvent(res, filter, event) {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeStreamEvent(res http.ResponseWriter, filter streamFilter, event stream.Event) bool {
+	if !filter.allows(event) {
+		return true
+	}
+	_, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err == nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/suggest"
+	"github.com/google/uuid"
+)
+
+const (
+	suggestionsInterval = 30 * time.Minute
+	suggestionsPerUser  = 20
+)
+
+type JsonSuggestion struct {
+	UserID         uuid.UUID `json:"user_id"`
+	Score          int32     `json:"score"`
+	MutualFollows  int32     `json:"mutual_follows"`
+	SharedHashtags int32     `json:"shared_hashtags"`
+	RecentChirps   int32     `json:"recent_chirps"`
+	ComputedAt     time.Time `json:"computed_at"`
+}
+
+// runSuggestionsJob recomputes the user_suggestions cache now and then
+// every interval, so GET /api/users/suggestions never has to walk the
+// follow graph itself
+func (cfg *apiConfig) runSuggestionsJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.computeSuggestions(context.Background()); err != nil {
+			log.Printf("error computing follow suggestions: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) computeSuggestions(ctx context.Context) error {
+	activity, err := cfg.DB.GetRecentChirpCounts(ctx)
+	if err != nil {
+		return err
+	}
+	recentChirps := map[uuid.UUID]int64{}
+	for _, row := range activity {
+		recentChirps[row.UserID] = row.RecentChirps
+	}
+	tags, err := cfg.DB.GetRecentHashtags(ctx)
+	if err != nil {
+		return err
+	}
+	hashtags := suggest.NewHashtags()
+	for _, row := range tags {
+		hashtags.Add(row.UserID, row.Tag, !row.IsProtected)
+	}
+	userIds, err := cfg.DB.GetAllUserIds(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err := cfg.computeUserSuggestions(ctx, userId, recentChirps, hashtags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) computeUserSuggestions(ctx context.Context, userId uuid.UUID, recentChirps map[uuid.UUID]int64, hashtags *suggest.Hashtags) error {
+	candidates := map[uuid.UUID]suggest.Signals{}
+	friends, err := cfg.DB.GetFriendsOfFriends(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, row := range friends {
+		signals := candidates[row.CandidateID]
+		signals.MutualFollows = row.MutualFollows
+		candidates[row.CandidateID] = signals
+	}
+	for id, shared := range hashtags.Shared(userId) {
+		signals := candidates[id]
+		signals.SharedHashtags = shared
+		candidates[id] = signals
+	}
+	excluded, err := cfg.DB.GetSuggestionExclusions(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, id := range excluded {
+		delete(candidates, id)
+	}
+	for id, signals := range candidates {
+		signals.RecentChirps = recentChirps[id]
+		candidates[id] = signals
+	}
+
+	ranked := suggest.Rank(candidates, suggestionsPerUser)
+	return cfg.withTx(ctx, func(q *database.Queries) error {
+		if err := q.DeleteUserSuggestions(ctx, userId); err != nil {
+			return err
+		}
+		for _, s := range ranked {
+			if err := q.CreateUserSuggestion(ctx, database.CreateUserSuggestionParams{
+				UserID:         userId,
+				SuggestedID:    s.UserID,
+				Score:          int32(s.Score),
+				MutualFollows:  int32(s.MutualFollows),
+				SharedHashtags: int32(s.SharedHashtags),
+				RecentChirps:   int32(s.RecentChirps),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSuggestionsHandler serves the cached who-to-follow suggestions,
+// dropping anyone the caller followed or blocked, or who blocked the caller
+// or was suspended, since they were computed
+func (cfg *apiConfig) GetSuggestionsHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeFollowsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	suggestions, err := cfg.DB.GetUserSuggestions(req.Context(), database.GetUserSuggestionsParams{
+		UserID: userId,
+		Limit:  suggestionsPerUser,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonSuggestion{}
+	for _, s := range suggestions {
+		ResBody = append(ResBody, JsonSuggestion{
+			UserID:         s.SuggestedID,
+			Score:          s.Score,
+			MutualFollows:  s.MutualFollows,
+			SharedHashtags: s.SharedHashtags,
+			RecentChirps:   s.RecentChirps,
+			ComputedAt:     s.ComputedAt,
+		})
+	}
+	respondWithPayload(res, 200, ResBody)
+}
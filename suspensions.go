@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// isAdmin reports whether the request carries
+// "Authorization: ApiKey <ADMIN_API_KEY>"
+func (cfg *apiConfig) isAdmin(req *http.Request) bool {
+	key, err := auth.GetAPIKey(req.Header)
+	return err == nil && cfg.AdminAPIKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(cfg.AdminAPIKey)) == 1
+}
+
+// AdminSuspendUserHandler suspends an account. For now a suspended account
+// is only kept out of who-to-follow suggestions.
+func (cfg *apiConfig) AdminSuspendUserHandler(res http.ResponseWriter, req *http.Request) {
+	if !cfg.isAdmin(req) {
+		respondWithError(res, 401, "invalid api key")
+		return
+	}
+	userId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(res, 404, "user not found")
+		return
+	}
+	if _, err := cfg.DB.GetUserById(req.Context(), userId); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(res, 404, "user not found")
+			return
+		}
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if err := cfg.DB.SuspendUser(req.Context(), userId); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
+
+// AdminUnsuspendUserHandler lifts a suspension
+func (cfg *apiConfig) AdminUnsuspendUserHandler(res http.ResponseWriter, req *http.Request) {
+	if !cfg.isAdmin(req) {
+		respondWithError(res, 401, "invalid api key")
+		return
+	}
+	userId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.UnsuspendUser(req.Context(), userId)
+	respondToRowChange(res, result, err)
+}
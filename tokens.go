@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAccessTokenTTL  = time.Hour
+	defaultRefreshTokenTTL = 60 * 24 * time.Hour
+	// jwtLeeway is the clock skew tolerated between this server and
+	// whoever else issues or checks access tokens
+	jwtLeeway = 30 * time.Second
+)
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// issueRefreshToken stores the digest of a new refresh token in the family
+// and returns the token itself. parentHash is the digest of the token it
+// replaces, or empty for the first token of a login.
+func (cfg *apiConfig) issueRefreshToken(ctx context.Context, q *database.Queries, userId, familyId uuid.UUID, parentHash string) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	_, err = q.CreateRefereshToken(ctx, database.CreateRefereshTokenParams{
+		TokenHash: auth.HashToken(token),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(cfg.RefreshTokenTTL),
+		UserID:    userId,
+		FamilyID:  familyId,
+		ParentHash: sql.NullString{
+			String: parentHash,
+			Valid:  parentHash != "",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// rotateRefreshToken uses up rt and issues its replacement, recording the
+// request on the session. It returns errRefreshTokenReused if rt was
+// rotated in the meantime.
+func (cfg *apiConfig) rotateRefreshToken(req *http.Request, rt database.RefreshToken) (string, error) {
+	ctx := req.Context()
+	now := time.Now().UTC()
+	var token string
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		result, err := q.RotateRefreshToken(ctx, database.RotateRefreshTokenParams{
+			RotatedAt: sql.NullTime{Time: now, Valid: true},
+			TokenHash: rt.TokenHash,
+		})
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errRefreshTokenReused
+		}
+		if err := q.TouchSession(ctx, database.TouchSessionParams{
+			LastUsedAt: now,
+			UserAgent:  sessionUserAgent(req),
+			Ip:         clientIP(req),
+			ID:         rt.FamilyID,
+		}); err != nil {
+			return err
+		}
+		token, err = cfg.issueRefreshToken(ctx, q, rt.UserID, rt.FamilyID, rt.TokenHash)
+		return err
+	})
+	return token, err
+}
+
+// revokeRefreshTokenFamily ends the login a reused token belongs to. Both
+// the thief and the legitimate client have to log in again.
+func (cfg *apiConfig) revokeRefreshTokenFamily(ctx context.Context, familyId uuid.UUID) error {
+	return cfg.DB.RevokeRefreshTokenFamily(ctx, database.RevokeRefreshTokenFamilyParams{
+		RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		FamilyID:  familyId,
+	})
+}
+
+// durationEnv reads a duration such as "15m" from the environment
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 15m", name)
+	}
+	return d, nil
+}
+
+func (cfg *apiConfig) makeAccessToken(userId uuid.UUID, scopes ...string) (string, error) {
+	return cfg.JWTKeys.MakeJWT(userId, cfg.AccessTokenTTL, scopes...)
+}
+
+// validateAccessToken checks an access token and that it hasn't been
+// revoked
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, err := cfg.JWTKeys.ParseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	jti, err := claims.TokenID()
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := cfg.DB.IsAccessTokenRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("token revoked")
+	}
+	return claims, nil
+}
+
+// revokeAccessToken puts a jti on the denylist until the token would have
+// expired anyway, and clears out entries that are no longer needed
+func (cfg *apiConfig) revokeAccessToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error {
+	if err := cfg.DB.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		Jti:       jti,
+		ExpiresAt: expiresAt.UTC().Add(jwtLeeway),
+	}); err != nil {
+		return err
+	}
+	return cfg.DB.DeleteExpiredRevokedAccessTokens(ctx)
+}
+
+// revokeAccessTokenString revokes an access token if it is still valid
+func (cfg *apiConfig) revokeAccessTokenString(ctx context.Context, token string) error {
+	claims, err := cfg.validateAccessToken(ctx, token)
+	if err != nil {
+		return nil
+	}
+	jti, err := claims.TokenID()
+	if err != nil {
+		return nil
+	}
+	return cfg.revokeAccessToken(ctx, jti, claims.ExpiresAt.Time)
+}
+
+// AdminRevokeAccessTokenHandler revokes any access token by jti. Callers
+// authenticate with "Authorization: ApiKey <ADMIN_API_KEY>".
+func (cfg *apiConfig) AdminRevokeAccessTokenHandler(res http.ResponseWriter, req *http.Request) {
+	if !cfg.isAdmin(req) {
+		respondWithError(res, 401, "invalid api key")
+		return
+	}
+	ReqBody := struct {
+		Jti uuid.UUID `json:"jti"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&ReqBody); err != nil || ReqBody.Jti == uuid.Nil {
+		respondWithError(res, 400, "jti is required")
+		return
+	}
+	// the token's expiry isn't known here, but no token lives longer
+	// than AccessTokenTTL
+	if err := cfg.revokeAccessToken(req.Context(), ReqBody.Jti, time.Now().Add(cfg.AccessTokenTTL)); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer        = "Chirpy"
+	recoveryCodeCount = 10
+	// a login challenge must be answered within loginChallengeTTL, and is
+	// thrown away after loginChallengeAttempts wrong codes
+	loginChallengeTTL      = 5 * time.Minute
+	loginChallengeAttempts = 5
+	// after totpMaxFailures wrong codes in a row, however they were sent,
+	// codes aren't checked for totpLockout
+	totpMaxFailures = 10
+	totpLockout     = 15 * time.Minute
+)
+
+var errSecondFactorLocked = errors.New("too many wrong codes, try again later")
+
+type JsonTwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type JsonTOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type JsonRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type JsonLoginChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// twoFactorSecret returns userId's TOTP secret and whether two-factor
+// authentication is on, which it is once the secret has been confirmed
+func (cfg *apiConfig) twoFactorSecret(ctx context.Context, userId uuid.UUID) (database.TotpSecret, bool, error) {
+	totp, err := cfg.DB.GetTOTPSecret(ctx, userId)
+	if err == sql.ErrNoRows {
+		return database.TotpSecret{}, false, nil
+	}
+	if err != nil {
+		return database.TotpSecret{}, false, err
+	}
+	return totp, totp.ConfirmedAt.Valid, nil
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery
+// code. Either is used up, so it can't be presented again. Wrong codes
+// count towards a lockout, reported as errSecondFactorLocked, so a fresh
+// login challenge doesn't mean a fresh set of guesses.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, totp database.TotpSecret, code string) (bool, error) {
+	if totp.LockedUntil.Valid && time.Now().UTC().Before(totp.LockedUntil.Time) {
+		return false, errSecondFactorLocked
+	}
+	code = strings.TrimSpace(code)
+	// a missing code isn't a guess, so it doesn't count as a failure
+	if code == "" {
+		return false, nil
+	}
+	var result sql.Result
+	var err error
+	if step, ok := auth.ValidateTOTP(totp.Secret, strings.ReplaceAll(code, " ", ""), time.Now()); ok {
+		result, err = cfg.DB.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			UserID:   totp.UserID,
+			LastStep: step,
+		})
+	} else {
+		result, err = cfg.DB.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   totp.UserID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected > 0 {
+		return true, cfg.DB.ResetTOTPFailures(ctx, totp.UserID)
+	}
+	return false, cfg.DB.RecordTOTPFailure(ctx, database.RecordTOTPFailureParams{
+		MaxFailures: totpMaxFailures,
+		LockedUntil: time.Now().UTC().Add(totpLockout),
+		UserID:      totp.UserID,
+	})
+}
+
+// respondToSecondFactorError answers a failed checkSecondFactor, with a
+// 429 while the user is locked out
+func respondToSecondFactorError(res http.ResponseWriter, err error) {
+	if err == errSecondFactorLocked {
+		res.Header().Set("Retry-After", strconv.Itoa(int(totpLockout.Seconds())))
+		respondWithError(res, 429, err.Error())
+		return
+	}
+	respondWithError(res, 500, err.Error())
+}
+
+// replaceRecoveryCodes swaps userId's recovery codes for a new set and
+// returns them. Only their digests are stored.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userId uuid.UUID) ([]string, error) {
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.DeleteRecoveryCodes(ctx, userId); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if err := q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// startLoginChallenge answers a correct password from a user with
+// two-factor authentication on. The challenge token stands in for the
+// password when the code is sent to /api/login/2fa.
+func (cfg *apiConfig) startLoginChallenge(res http.ResponseWriter, req *http.Request, userId uuid.UUID) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(res, 500, "unable to generate token")
+		return
+	}
+	expiresAt := time.Now().UTC().Add(loginChallengeTTL)
+	if err := cfg.DB.CreateLoginChallenge(req.Context(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if err := cfg.DB.DeleteExpiredLoginChallenges(req.Context()); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 200, JsonLoginChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+// Login2FAHandler completes a login that was answered with a challenge,
+// given a TOTP code or a recovery code
+func (cfg *apiConfig) Login2FAHandler(res http.ResponseWriter, req *http.Request) {
+	ReqBody := struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	tokenHash := auth.HashToken(ReqBody.ChallengeToken)
+	challenge, err := cfg.DB.GetLoginChallenge(req.Context(), tokenHash)
+	if err == sql.ErrNoRows {
+		respondWithError(res, 401, "invalid challenge token")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if time.Now().UTC().After(challenge.ExpiresAt) {
+		respondWithError(res, 401, "challenge token expired")
+		return
+	}
+	totp, enabled, err := cfg.twoFactorSecret(req.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	// if two-factor authentication was turned off in the meantime the
+	// password alone is enough
+	if enabled {
+		ok, err := cfg.checkSecondFactor(req.Context(), totp, ReqBody.Code)
+		if err != nil {
+			respondToSecondFactorError(res, err)
+			return
+		}
+		if !ok {
+			attempts, err := cfg.DB.RecordLoginChallengeFailure(req.Context(), tokenHash)
+			if err == nil && attempts >= loginChallengeAttempts {
+				err = cfg.DB.DeleteLoginChallenge(req.Context(), tokenHash)
+			}
+			if err != nil && err != sql.ErrNoRows {
+				respondWithError(res, 500, err.Error())
+				return
+			}
+			respondWithError(res, 401, "invalid code")
+			return
+		}
+	}
+	if err := cfg.DB.DeleteLoginChallenge(req.Context(), tokenHash); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	user, err := cfg.DB.GetUserById(req.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	cfg.respondWithLogin(res, req, user)
+}
+
+func (cfg *apiConfig) GetTwoFactorHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	_, enabled, err := cfg.twoFactorSecret(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := JsonTwoFactorStatus{Enabled: enabled}
+	if enabled {
+		ResBody.RecoveryCodesRemaining, err = cfg.DB.CountUnusedRecoveryCodes(req.Context(), userId)
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+// EnrollTwoFactorHandler generates a TOTP secret for the caller to add to
+// an authenticator app. Two-factor authentication only turns on once a
+// code from the app is sent to ConfirmTwoFactorHandler.
+func (cfg *apiConfig) EnrollTwoFactorHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	_, enabled, err := cfg.twoFactorSecret(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if enabled {
+		respondWithError(res, 409, "two-factor authentication is already enabled")
+		return
+	}
+	user, err := cfg.DB.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if err := cfg.DB.UpsertTOTPSecret(req.Context(), database.UpsertTOTPSecretParams{
+		UserID: userId,
+		Secret: secret,
+	}); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 200, JsonTOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactorHandler turns two-factor authentication on once the
+// caller proves their app has the secret, and returns the recovery codes
+func (cfg *apiConfig) ConfirmTwoFactorHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	ReqBody := struct {
+		Code string `json:"code"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	totp, err := cfg.DB.GetTOTPSecret(req.Context(), userId)
+	if err == sql.ErrNoRows {
+		respondWithError(res, 400, "enroll before confirming")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		respondWithError(res, 409, "two-factor authentication is already enabled")
+		return
+	}
+	step, ok := auth.ValidateTOTP(totp.Secret, strings.TrimSpace(ReqBody.Code), time.Now())
+	if !ok {
+		respondWithError(res, 400, "invalid code")
+		return
+	}
+	var codes []string
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		if err := q.ConfirmTOTPSecret(req.Context(), database.ConfirmTOTPSecretParams{
+			UserID:   userId,
+			LastStep: step,
+		}); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(req.Context(), q, userId)
+		return err
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 200, JsonRecoveryCodes{RecoveryCodes: codes})
+}
+
+// secondFactorFromBody authenticates the caller and checks the code in
+// the request body, for changes to two-factor authentication itself
+func (cfg *apiConfig) secondFactorFromBody(res http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return uuid.Nil, false
+	}
+	ReqBody := struct {
+		Code string `json:"code"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return uuid.Nil, false
+	}
+	totp, enabled, err := cfg.twoFactorSecret(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return uuid.Nil, false
+	}
+	if !enabled {
+		respondWithError(res, 400, "two-factor authentication is not enabled")
+		return uuid.Nil, false
+	}
+	ok, err := cfg.checkSecondFactor(req.Context(), totp, ReqBody.Code)
+	if err != nil {
+		respondToSecondFactorError(res, err)
+		return uuid.Nil, false
+	}
+	if !ok {
+		respondWithError(res, 401, "invalid code")
+		return uuid.Nil, false
+	}
+	return userId, true
+}
+
+// RegenerateRecoveryCodesHandler replaces the caller's recovery codes,
+// given a current code
+func (cfg *apiConfig) RegenerateRecoveryCodesHandler(res http.ResponseWriter, req *http.Request) {
+	userId, ok := cfg.secondFactorFromBody(res, req)
+	if !ok {
+		return
+	}
+	var codes []string
+	err := cfg.withTx(req.Context(), func(q *database.Queries) error {
+		var err error
+		codes, err = replaceRecoveryCodes(req.Context(), q, userId)
+		return err
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	respondWithPayload(res, 200, JsonRecoveryCodes{RecoveryCodes: codes})
+}
+
+// DisableTwoFactorHandler turns two-factor authentication off, given a
+// current code, so a stolen access token alone can't do it
+func (cfg *apiConfig) DisableTwoFactorHandler(res http.ResponseWriter, req *http.Request) {
+	userId, ok := cfg.secondFactorFromBody(res, req)
+	if !ok {
+		return
+	}
+	err := cfg.withTx(req.Context(), func(q *database.Queries) error {
+		if err := q.DeleteRecoveryCodes(req.Context(), userId); err != nil {
+			return err
+		}
+		return q.DeleteTOTPSecret(req.Context(), userId)
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	res.WriteHeader(204)
+}
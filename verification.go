@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/mail"
+	"github.com/google/uuid"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// Actions REQUIRE_VERIFIED_EMAIL can hold back until the user's email is
+// verified. Upgrades are still recorded, see chirpyRed.
+const (
+	verifiedForChirps   = "chirps"
+	verifiedForMessages = "messages"
+	verifiedForUpgrades = "upgrades"
+)
+
+var verifiableActions = map[string]bool{
+	verifiedForChirps:   true,
+	verifiedForMessages: true,
+	verifiedForUpgrades: true,
+}
+
+type JsonEmailVerification struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// loadVerifiedEmailActions parses the comma separated actions in
+// REQUIRE_VERIFIED_EMAIL
+func loadVerifiedEmailActions(raw string) (map[string]bool, error) {
+	actions := map[string]bool{}
+	for _, action := range strings.Split(raw, ",") {
+		action = strings.TrimSpace(action)
+		if action == "" {
+			continue
+		}
+		if !verifiableActions[action] {
+			return nil, fmt.Errorf("REQUIRE_VERIFIED_EMAIL: unknown action %q", action)
+		}
+		actions[action] = true
+	}
+	return actions, nil
+}
+
+// validEmail accepts a bare address such as name@example.com, without a
+// display name or angle brackets
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// sendVerificationEmail emails a link that verifies email for userId. It
+// runs in the background, so failures are only logged.
+func (cfg *apiConfig) sendVerificationEmail(userId uuid.UUID, email string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		token, err := auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("error generating an email verification token: %v", err)
+			return
+		}
+		if err := cfg.DB.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+			TokenHash: auth.HashToken(token),
+			UserID:    userId,
+			Email:     email,
+			ExpiresAt: time.Now().UTC().Add(emailVerificationTTL),
+		}); err != nil {
+			log.Printf("error storing an email verification token: %v", err)
+			return
+		}
+		if err := cfg.DB.DeleteExpiredEmailVerificationTokens(ctx); err != nil {
+			log.Printf("error deleting expired email verification tokens: %v", err)
+		}
+		link := cfg.BaseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+		cfg.sendMail(mail.Message{
+			To:      email,
+			Subject: "Verify your Chirpy email",
+			Body: fmt.Sprintf("Confirm that this is your email address by opening this link within the next day:\n\n%s\n\n"+
+				"If you didn't sign up for Chirpy, ignore this email.\n", link),
+		})
+	}()
+}
+
+// emailVerifiedFor reports whether userId may do action, which is always
+// the case unless REQUIRE_VERIFIED_EMAIL lists it
+func (cfg *apiConfig) emailVerifiedFor(ctx context.Context, userId uuid.UUID, action string) (bool, error) {
+	if !cfg.VerifiedEmailRequired[action] {
+		return true, nil
+	}
+	user, err := cfg.DB.GetUserById(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt.Valid, nil
+}
+
+// chirpyRed reports whether the user's Chirpy Red membership is in
+// effect. With upgrades in REQUIRE_VERIFIED_EMAIL a paid upgrade is kept
+// but only counts once the email is verified.
+func (cfg *apiConfig) chirpyRed(user database.User) bool {
+	if !user.IsChirpyRed {
+		return false
+	}
+	return !cfg.VerifiedEmailRequired[verifiedForUpgrades] || user.EmailVerifiedAt.Valid
+}
+
+// requireVerifiedEmail is emailVerifiedFor for API handlers. It answers
+// 403 when the email has to be verified first.
+func (cfg *apiConfig) requireVerifiedEmail(res http.ResponseWriter, req *http.Request, userId uuid.UUID, action string) bool {
+	ok, err := cfg.emailVerifiedFor(req.Context(), userId, action)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return false
+	}
+	if !ok {
+		respondWithError(res, 403, "verify your email address first")
+		return false
+	}
+	return true
+}
+
+// VerifyEmailHandler is where the emailed link leads. A token verifies
+// the address it was sent to and only works once.
+func (cfg *apiConfig) VerifyEmailHandler(res http.ResponseWriter, req *http.Request) {
+	token, err := cfg.DB.UseEmailVerificationToken(req.Context(), auth.HashToken(req.URL.Query().Get("token")))
+	if err == sql.ErrNoRows {
+		respondWithError(res, 400, "invalid or expired verification token")
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	result, err := cfg.DB.MarkEmailVerified(req.Context(), database.MarkEmailVerifiedParams{
+		ID:    token.UserID,
+		Email: token.Email,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if rowsAffected == 0 {
+		// either verified already through another link, or the email
+		// changed after this one was sent
+		user, err := cfg.DB.GetUserById(req.Context(), token.UserID)
+		if err != nil {
+			respondWithError(res, 500, err.Error())
+			return
+		}
+		if user.Email != token.Email || !user.EmailVerifiedAt.Valid {
+			respondWithError(res, 400, "the account's email changed after this link was sent")
+			return
+		}
+	}
+	respondWithPayload(res, 200, JsonEmailVerification{
+		Email:         token.Email,
+		EmailVerified: true,
+	})
+}
+
+// ResendVerificationEmailHandler sends the caller a new verification
+// link, for when the first one expired or got lost
+func (cfg *apiConfig) ResendVerificationEmailHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeAccount)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	user, err := cfg.DB.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(res, 409, "email is already verified")
+		return
+	}
+	cfg.sendVerificationEmail(user.ID, user.Email)
+	res.WriteHeader(202)
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/auth"
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	sessionCookie   = "chirpy_session"
+	webTimelineSize = 50
+	maxChirpLength  = 140
+	webCSP          = "default-src 'self'; style-src 'unsafe-inline'; img-src 'self' https: http:; frame-ancestors 'none'; base-uri 'none'; form-action 'self'"
+	// pqUniqueViolation is the Postgres error code for a duplicate key
+	pqUniqueViolation = "23505"
+)
+
+var webTemplates = map[string]*template.Template{
+	"timeline":  parseWebTemplate("timeline.html"),
+	"chirp":     parseWebTemplate("chirp.html"),
+	"profile":   parseWebTemplate("profile.html"),
+	"login":     parseWebTemplate("login.html"),
+	"signup":    parseWebTemplate("signup.html"),
+	"not_found": parseWebTemplate("not_found.html"),
+	"forgot":    parseWebTemplate("forgot_password.html"),
+	"reset":     parseWebTemplate("reset_password.html"),
+}
+
+// parseWebTemplate parses a page together with the shared layout, which
+// the page fills in by defining "content" and optionally "head"
+func parseWebTemplate(page string) *template.Template {
+	return template.Must(template.ParseFS(templateFS, "templates/web/layout.html", "templates/web/"+page))
+}
+
+// webPage is the data every page template is executed with. Pages only
+// use the fields they need.
+type webPage struct {
+	Title    string
+	ViewerID uuid.UUID
+	Error    string
+	Notice   string
+	// Email and Body refill a form that was rejected
+	Email     string
+	Body      string
+	Chirp     JsonChirp
+	Chirps    []JsonChirp
+	Profile   webProfile
+	OEmbedURL string
+	// Token carries a password reset token through the reset form
+	Token string
+}
+
+func (p webPage) SignedIn() bool {
+	return p.ViewerID != uuid.Nil
+}
+
+type webProfile struct {
+	ID          uuid.UUID
+	IsChirpyRed bool
+	IsProtected bool
+	// Hidden is set when the viewer may not see the profile's chirps
+	Hidden bool
+}
+
+func (cfg *apiConfig) chirpPageURL(chirpId uuid.UUID) string {
+	return cfg.BaseURL + "/app/chirps/" + chirpId.String()
+}
+
+func (cfg *apiConfig) profilePageURL(userId uuid.UUID) string {
+	return cfg.BaseURL + "/app/users/" + userId.String()
+}
+
+// webViewerID is viewerID for the web UI, which keeps the JWT in a
+// cookie instead of the Authorization header
+func (cfg *apiConfig) webViewerID(req *http.Request) uuid.UUID {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return uuid.Nil
+	}
+	claims, err := cfg.validateAccessToken(req.Context(), cookie.Value)
+	if err != nil {
+		return uuid.Nil
+	}
+	userId, err := claims.UserID()
+	if err != nil {
+		return uuid.Nil
+	}
+	return userId
+}
+
+func (cfg *apiConfig) setSession(res http.ResponseWriter, token string, maxAge time.Duration) {
+	http.SetCookie(res, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/app",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// signIn starts a web session for userId and sends the browser to the
+// timeline
+func (cfg *apiConfig) signIn(res http.ResponseWriter, req *http.Request, userId uuid.UUID) {
+	token, err := cfg.makeAccessToken(userId)
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	// the cookie lasts as long as the JWT it holds
+	cfg.setSession(res, token, cfg.AccessTokenTTL)
+	http.Redirect(res, req, "/app/", http.StatusSeeOther)
+}
+
+// sameOrigin rejects form posts made by other sites. Browsers send
+// Sec-Fetch-Site or Origin with every POST, so requests carrying neither
+// come from non-browser clients, which can't ride on a user's cookie.
+func sameOrigin(req *http.Request) bool {
+	if site := req.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+func renderPage(res http.ResponseWriter, code int, name string, page webPage) {
+	var buf bytes.Buffer
+	if err := webTemplates[name].ExecuteTemplate(&buf, "layout", page); err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("Content-Security-Policy", webCSP)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	// pages differ per signed in user
+	res.Header().Set("Cache-Control", "private, no-cache")
+	res.WriteHeader(code)
+	res.Write(buf.Bytes())
+}
+
+func renderNotFound(res http.ResponseWriter, viewerId uuid.UUID) {
+	renderPage(res, 404, "not_found", webPage{Title: "Not found", ViewerID: viewerId})
+}
+
+// webChirps converts chirps for a page and counts them as seen
+func (cfg *apiConfig) webChirps(req *http.Request, viewerId uuid.UUID, chirps []database.Chirp) ([]JsonChirp, error) {
+	cfg.recordImpressions(viewerId, chirps...)
+	jsonChirps := make([]JsonChirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		jsonChirps = append(jsonChirps, jsonChirpFromDB(chirp))
+	}
+	if err := cfg.withPreviews(req.Context(), jsonChirps); err != nil {
+		return nil, err
+	}
+	return jsonChirps, nil
+}
+
+// renderTimeline shows the newest chirps the viewer may see, the same
+// ones GET /api/chirps returns
+func (cfg *apiConfig) renderTimeline(res http.ResponseWriter, req *http.Request, code int, page webPage) {
+	chirps, err := cfg.DB.GetRecentVisibleChirps(req.Context(), database.GetRecentVisibleChirpsParams{
+		ViewerID: page.ViewerID,
+		MaxItems: webTimelineSize,
+	})
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	page.Chirps, err = cfg.webChirps(req, page.ViewerID, chirps)
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	page.Title = "Timeline"
+	renderPage(res, code, "timeline", page)
+}
+
+func (cfg *apiConfig) WebTimelineHandler(res http.ResponseWriter, req *http.Request) {
+	cfg.renderTimeline(res, req, 200, webPage{ViewerID: cfg.webViewerID(req)})
+}
+
+func (cfg *apiConfig) WebNotFoundHandler(res http.ResponseWriter, req *http.Request) {
+	renderNotFound(res, cfg.webViewerID(req))
+}
+
+func (cfg *apiConfig) WebChirpHandler(res http.ResponseWriter, req *http.Request) {
+	viewerId := cfg.webViewerID(req)
+	chirpId, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		renderNotFound(res, viewerId)
+		return
+	}
+	chirp, err := cfg.DB.GetVisibleChirpById(req.Context(), database.GetVisibleChirpByIdParams{
+		ID:       chirpId,
+		ViewerID: viewerId,
+	})
+	if err == sql.ErrNoRows {
+		renderNotFound(res, viewerId)
+		return
+	}
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	chirps, err := cfg.webChirps(req, viewerId, []database.Chirp{chirp})
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	renderPage(res, 200, "chirp", webPage{
+		Title:     "Chirp by " + chirp.UserID.String(),
+		ViewerID:  viewerId,
+		Chirp:     chirps[0],
+		OEmbedURL: cfg.BaseURL + "/api/oembed?url=" + url.QueryEscape(cfg.chirpPageURL(chirp.ID)),
+	})
+}
+
+// WebProfileHandler shows a user's newest chirps. Protected accounts only
+// show them to the owner and approved followers.
+func (cfg *apiConfig) WebProfileHandler(res http.ResponseWriter, req *http.Request) {
+	viewerId := cfg.webViewerID(req)
+	userId, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		renderNotFound(res, viewerId)
+		return
+	}
+	user, err := cfg.DB.GetUserById(req.Context(), userId)
+	if err == sql.ErrNoRows {
+		renderNotFound(res, viewerId)
+		return
+	}
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	page := webPage{
+		Title:    user.ID.String(),
+		ViewerID: viewerId,
+		Profile: webProfile{
+			ID:          user.ID,
+			IsChirpyRed: cfg.chirpyRed(user),
+			IsProtected: user.IsProtected,
+		},
+	}
+	if user.IsProtected {
+		audience, err := cfg.loadAudience(req.Context(), viewerId)
+		if err != nil {
+			http.Error(res, "internal server error", 500)
+			return
+		}
+		page.Profile.Hidden = !audience.follows(user.ID)
+	}
+	if !page.Profile.Hidden {
+		chirps, err := cfg.DB.GetRecentChirpsByUser(req.Context(), database.GetRecentChirpsByUserParams{
+			UserID: user.ID,
+			Limit:  webTimelineSize,
+		})
+		if err != nil {
+			http.Error(res, "internal server error", 500)
+			return
+		}
+		page.Chirps, err = cfg.webChirps(req, viewerId, chirps)
+		if err != nil {
+			http.Error(res, "internal server error", 500)
+			return
+		}
+	}
+	renderPage(res, 200, "profile", page)
+}
+
+func (cfg *apiConfig) WebCreateChirpHandler(res http.ResponseWriter, req *http.Request) {
+	if !sameOrigin(req) {
+		http.Error(res, "cross-site form posts are not allowed", 403)
+		return
+	}
+	viewerId := cfg.webViewerID(req)
+	if viewerId == uuid.Nil {
+		http.Redirect(res, req, "/app/login", http.StatusSeeOther)
+		return
+	}
+	body := strings.TrimSpace(req.FormValue("body"))
+	if body == "" || len(body) > maxChirpLength {
+		cfg.renderTimeline(res, req, 400, webPage{
+			ViewerID: viewerId,
+			Error:    "Chirps must be between 1 and 140 characters",
+			Body:     body,
+		})
+		return
+	}
+	verified, err := cfg.emailVerifiedFor(req.Context(), viewerId, verifiedForChirps)
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	if !verified {
+		cfg.renderTimeline(res, req, 403, webPage{
+			ViewerID: viewerId,
+			Error:    "Verify your email address before posting. Check your inbox for the link.",
+			Body:     body,
+		})
+		return
+	}
+	if _, err := cfg.createChirp(req, viewerId, body); err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	http.Redirect(res, req, "/app/", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) WebLoginPageHandler(res http.ResponseWriter, req *http.Request) {
+	renderPage(res, 200, "login", webPage{Title: "Log in", ViewerID: cfg.webViewerID(req)})
+}
+
+func (cfg *apiConfig) WebLoginHandler(res http.ResponseWriter, req *http.Request) {
+	if !sameOrigin(req) {
+		http.Error(res, "cross-site form posts are not allowed", 403)
+		return
+	}
+	email := strings.TrimSpace(req.FormValue("email"))
+	user, err := cfg.DB.GetUserByEmail(req.Context(), email)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	if err == sql.ErrNoRows || auth.CheckPasswordHash(req.FormValue("password"), user.HashedPassword) != nil {
+		renderPage(res, 401, "login", webPage{
+			Title: "Log in",
+			Error: "Incorrect email or password",
+			Email: email,
+		})
+		return
+	}
+	totp, enabled, err := cfg.twoFactorSecret(req.Context(), user.ID)
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	if enabled {
+		ok, err := cfg.checkSecondFactor(req.Context(), totp, req.FormValue("code"))
+		if err == errSecondFactorLocked {
+			renderPage(res, 429, "login", webPage{
+				Title: "Log in",
+				Error: "Too many wrong codes. Try again in 15 minutes.",
+				Email: email,
+			})
+			return
+		}
+		if err != nil {
+			http.Error(res, "internal server error", 500)
+			return
+		}
+		if !ok {
+			renderPage(res, 401, "login", webPage{
+				Title: "Log in",
+				Error: "Enter a current code from your authenticator app or a recovery code",
+				Email: email,
+			})
+			return
+		}
+	}
+	cfg.signIn(res, req, user.ID)
+}
+
+func (cfg *apiConfig) WebForgotPasswordPageHandler(res http.ResponseWriter, req *http.Request) {
+	renderPage(res, 200, "forgot", webPage{Title: "Forgot password", ViewerID: cfg.webViewerID(req)})
+}
+
+func (cfg *apiConfig) WebForgotPasswordHandler(res http.ResponseWriter, req *http.Request) {
+	if !sameOrigin(req) {
+		http.Error(res, "cross-site form posts are not allowed", 403)
+		return
+	}
+	go cfg.requestPasswordReset(strings.TrimSpace(req.FormValue("email")))
+	renderPage(res, 202, "forgot", webPage{
+		Title:  "Forgot password",
+		Notice: "If an account uses that email, a link to reset its password is on its way.",
+	})
+}
+
+func (cfg *apiConfig) WebResetPasswordPageHandler(res http.ResponseWriter, req *http.Request) {
+	renderPage(res, 200, "reset", webPage{Title: "Reset password", Token: req.URL.Query().Get("token")})
+}
+
+func (cfg *apiConfig) WebResetPasswordHandler(res http.ResponseWriter, req *http.Request) {
+	if !sameOrigin(req) {
+		http.Error(res, "cross-site form posts are not allowed", 403)
+		return
+	}
+	token := req.FormValue("token")
+	password := req.FormValue("password")
+	if password == "" {
+		renderPage(res, 400, "reset", webPage{Title: "Reset password", Error: "Password is required", Token: token})
+		return
+	}
+	err := cfg.resetPassword(req.Context(), token, password)
+	if err == errInvalidResetToken {
+		renderPage(res, 400, "reset", webPage{
+			Title: "Reset password",
+			Error: "This reset link is invalid or has expired. Ask for a new one.",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	renderPage(res, 200, "login", webPage{
+		Title:  "Log in",
+		Notice: "Your password was changed. Log in with the new one.",
+	})
+}
+
+func (cfg *apiConfig) WebSignupPageHandler(res http.ResponseWriter, req *http.Request) {
+	renderPage(res, 200, "signup", webPage{Title: "Sign up", ViewerID: cfg.webViewerID(req)})
+}
+
+func (cfg *apiConfig) WebSignupHandler(res http.ResponseWriter, req *http.Request) {
+	if !sameOrigin(req) {
+		http.Error(res, "cross-site form posts are not allowed", 403)
+		return
+	}
+	email := strings.TrimSpace(req.FormValue("email"))
+	password := req.FormValue("password")
+	if email == "" || password == "" {
+		renderPage(res, 400, "signup", webPage{
+			Title: "Sign up",
+			Error: "Email and password are required",
+			Email: email,
+		})
+		return
+	}
+	if !validEmail(email) {
+		renderPage(res, 400, "signup", webPage{
+			Title: "Sign up",
+			Error: "That doesn't look like an email address",
+			Email: email,
+		})
+		return
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	user, err := cfg.DB.CreateUser(req.Context(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		renderPage(res, 409, "signup", webPage{
+			Title: "Sign up",
+			Error: "An account with that email already exists",
+			Email: email,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(res, "internal server error", 500)
+		return
+	}
+	cfg.sendVerificationEmail(user.ID, user.Email)
+	cfg.signIn(res, req, user.ID)
+}
+
+func (cfg *apiConfig) WebLogoutHandler(res http.ResponseWriter, req *http.Request) {
+	if !sameOrigin(req) {
+		http.Error(res, "cross-site form posts are not allowed", 403)
+		return
+	}
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		if err := cfg.revokeAccessTokenString(req.Context(), cookie.Value); err != nil {
+			http.Error(res, "internal server error", 500)
+			return
+		}
+	}
+	cfg.setSession(res, "", -time.Second)
+	http.Redirect(res, req, "/app/", http.StatusSeeOther)
+}
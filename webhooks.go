@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/database"
+	"github.com/P-H-Pancholi/Chirpy/internal/eventbus"
+	"github.com/P-H-Pancholi/Chirpy/internal/preview"
+	"github.com/P-H-Pancholi/Chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+	webhookMaxAttempts  = 8
+	// webhooks are disabled after this many failed attempts in a row
+	webhookMaxFailures = 20
+	webhooksPerUser    = 10
+	webhookLogSize     = 50
+)
+
+// webhookEvents are the bus events users can subscribe webhooks to
+var webhookEvents = map[string]bool{
+	eventbus.ChirpCreated:  true,
+	eventbus.ChirpDeleted:  true,
+	eventbus.FollowCreated: true,
+	eventbus.UserUpgraded:  true,
+}
+
+const (
+	deliveryPending   = "pending"
+	deliverySucceeded = "succeeded"
+	deliveryFailed    = "failed"
+)
+
+type JsonWebhook struct {
+	ID                  uuid.UUID `json:"id"`
+	URL                 string    `json:"url"`
+	Events              []string  `json:"events"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int32     `json:"consecutive_failures"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	// Secret is only returned when the webhook is created
+	Secret string `json:"secret,omitempty"`
+}
+
+func jsonWebhookFromDB(hook database.Webhook) JsonWebhook {
+	return JsonWebhook{
+		ID:                  hook.ID,
+		URL:                 hook.Url,
+		Events:              hook.Events,
+		Active:              hook.Active,
+		ConsecutiveFailures: hook.ConsecutiveFailures,
+		CreatedAt:           hook.CreatedAt,
+		UpdatedAt:           hook.UpdatedAt,
+	}
+}
+
+type JsonWebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus *int32          `json:"response_status"`
+	Error          *string         `json:"error"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+func jsonWebhookDeliveryFromDB(d database.WebhookDelivery) JsonWebhookDelivery {
+	delivery := JsonWebhookDelivery{
+		ID:        d.ID,
+		Event:     d.Event,
+		Payload:   json.RawMessage(d.Payload),
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+	if d.ResponseStatus.Valid {
+		delivery.ResponseStatus = &d.ResponseStatus.Int32
+	}
+	if d.Error.Valid {
+		delivery.Error = &d.Error.String
+	}
+	if d.Status == deliveryPending {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+	return delivery
+}
+
+// webhookTarget returns the users whose webhooks hear about an event and
+// the data to send them. Webhooks only ever see events about their owner.
+func webhookTarget(data interface{}) ([]uuid.UUID, interface{}) {
+	switch d := data.(type) {
+	case chirpEventData:
+		return []uuid.UUID{d.Chirp.UserID}, d.Chirp
+	case JsonFollow:
+		return []uuid.UUID{d.FollowerID, d.FolloweeID}, d
+	case userEventData:
+		return []uuid.UUID{d.UserID}, d
+	}
+	return nil, nil
+}
+
+// queueWebhooks records a pending delivery for every webhook subscribed to
+// the event. It runs on the instance that published the event, so each
+// delivery is queued once however many instances share the bus.
+func (cfg *apiConfig) queueWebhooks(ctx context.Context, typ string, data interface{}) {
+	if !webhookEvents[typ] {
+		return
+	}
+	owners, payload := webhookTarget(data)
+	if len(owners) == 0 {
+		return
+	}
+	body, err := webhook.NewPayload(typ, payload)
+	if err != nil {
+		log.Printf("error queueing %s webhooks: %v", typ, err)
+		return
+	}
+	for _, owner := range owners {
+		hooks, err := cfg.DB.GetActiveWebhooksForEvent(ctx, database.GetActiveWebhooksForEventParams{
+			UserID: owner,
+			Event:  typ,
+		})
+		if err != nil {
+			log.Printf("error queueing %s webhooks: %v", typ, err)
+			return
+		}
+		for _, hook := range hooks {
+			if err := cfg.DB.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+				WebhookID: hook.ID,
+				Event:     typ,
+				Payload:   string(body),
+			}); err != nil {
+				log.Printf("error queueing %s webhook %s: %v", typ, hook.ID, err)
+			}
+		}
+	}
+}
+
+// runWebhookWorker sends due deliveries every interval. Rows are claimed
+// with SKIP LOCKED, so every instance can run one. The client refuses
+// internal addresses when it connects, which also covers hosts that
+// resolved to a public address when the webhook was created.
+func (cfg *apiConfig) runWebhookWorker(interval time.Duration) {
+	client := preview.NewPublicClient(webhookTimeout)
+	// a redirect counts as a failed delivery rather than being followed
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.sendDueWebhooks(context.Background(), client); err != nil {
+			log.Printf("error sending webhooks: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) sendDueWebhooks(ctx context.Context, client *http.Client) error {
+	deliveries, err := cfg.DB.ClaimDueWebhookDeliveries(ctx, webhookBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		status, sendErr := webhook.Send(ctx, client, d.Url, d.Secret, d.ID.String(), d.Event, []byte(d.Payload))
+		if err := cfg.recordWebhookAttempt(ctx, d, status, sendErr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordWebhookAttempt logs one attempt on the delivery, schedules a retry
+// with backoff, and disables the webhook once it keeps failing
+func (cfg *apiConfig) recordWebhookAttempt(ctx context.Context, d database.ClaimDueWebhookDeliveriesRow, status int, sendErr error) error {
+	update := database.UpdateWebhookDeliveryParams{
+		ID:            d.ID,
+		Status:        deliverySucceeded,
+		Attempts:      d.Attempts + 1,
+		NextAttemptAt: time.Now().UTC(),
+	}
+	if status != 0 {
+		update.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	return cfg.withTx(ctx, func(q *database.Queries) error {
+		if sendErr == nil {
+			if err := q.UpdateWebhookDelivery(ctx, update); err != nil {
+				return err
+			}
+			return q.RecordWebhookSuccess(ctx, d.WebhookID)
+		}
+		update.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+		update.Status = deliveryPending
+		update.NextAttemptAt = update.NextAttemptAt.Add(webhook.Backoff(int(update.Attempts)))
+		if update.Attempts >= webhookMaxAttempts {
+			update.Status = deliveryFailed
+		}
+		if err := q.UpdateWebhookDelivery(ctx, update); err != nil {
+			return err
+		}
+		active, err := q.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{
+			MaxFailures: webhookMaxFailures,
+			ID:          d.WebhookID,
+		})
+		if err == nil && !active {
+			log.Printf("webhook %s disabled after %d consecutive failures", d.WebhookID, webhookMaxFailures)
+		}
+		return err
+	})
+}
+
+// checkWebhookURL rejects URLs that aren't absolute http or https URLs or
+// whose host resolves to an internal address, so webhooks can't be used
+// to probe the network Chirpy runs in
+func checkWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.New("url's host could not be resolved")
+	}
+	for _, addr := range addrs {
+		if !preview.PublicAddr(addr) {
+			return errors.New("url must point to a public address")
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) CreateWebhookHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeWebhooksWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	ReqBody := struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}{}
+	decoder := json.NewDecoder(req.Body)
+	defer req.Body.Close()
+	if err := decoder.Decode(&ReqBody); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	if err := checkWebhookURL(req.Context(), ReqBody.URL); err != nil {
+		respondWithError(res, 400, err.Error())
+		return
+	}
+	events := []string{}
+	seen := map[string]bool{}
+	for _, event := range ReqBody.Events {
+		if !webhookEvents[event] {
+			respondWithError(res, 400, "unknown event "+event)
+			return
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		respondWithError(res, 400, "at least one event is required")
+		return
+	}
+	existing, err := cfg.DB.GetUserWebhooks(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	if len(existing) >= webhooksPerUser {
+		respondWithError(res, 400, "too many webhooks")
+		return
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	hook, err := cfg.DB.CreateWebhook(req.Context(), database.CreateWebhookParams{
+		UserID: userId,
+		Url:    ReqBody.URL,
+		Secret: hex.EncodeToString(secret),
+		Events: events,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := jsonWebhookFromDB(hook)
+	ResBody.Secret = hook.Secret
+	respondWithPayload(res, 201, ResBody)
+}
+
+func (cfg *apiConfig) GetWebhooksHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeWebhooksRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	hooks, err := cfg.DB.GetUserWebhooks(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonWebhook{}
+	for _, hook := range hooks {
+		ResBody = append(ResBody, jsonWebhookFromDB(hook))
+	}
+	respondWithPayload(res, 200, ResBody)
+}
+
+func (cfg *apiConfig) DeleteWebhookHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeWebhooksWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	webhookId, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.DeleteWebhook(req.Context(), database.DeleteWebhookParams{
+		ID:     webhookId,
+		UserID: userId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+// EnableWebhookHandler turns a disabled webhook back on. Deliveries still
+// pending when it was disabled are sent again.
+func (cfg *apiConfig) EnableWebhookHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeWebhooksWrite)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	webhookId, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	result, err := cfg.DB.EnableWebhook(req.Context(), database.EnableWebhookParams{
+		ID:     webhookId,
+		UserID: userId,
+	})
+	respondToRowChange(res, result, err)
+}
+
+// GetWebhookDeliveriesHandler returns the webhook's most recent deliveries
+func (cfg *apiConfig) GetWebhookDeliveriesHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeWebhooksRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	webhookId, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		res.WriteHeader(404)
+		return
+	}
+	hook, err := cfg.DB.GetWebhookById(req.Context(), database.GetWebhookByIdParams{
+		ID:     webhookId,
+		UserID: userId,
+	})
+	if err == sql.ErrNoRows {
+		res.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	deliveries, err := cfg.DB.GetWebhookDeliveries(req.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit:     webhookLogSize,
+	})
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	ResBody := []JsonWebhookDelivery{}
+	for _, d := range deliveries {
+		ResBody = append(ResBody, jsonWebhookDeliveryFromDB(d))
+	}
+	respondWithPayload(res, 200, ResBody)
+}
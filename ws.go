@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/P-H-Pancholi/Chirpy/internal/stream"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsWriteWait    = 10 * time.Second
+	// wsSendQueue is how many frames may wait for a slow client before the
+	// connection is closed instead of buffering without bound
+	wsSendQueue = 64
+	wsReadLimit = 4096
+	wsMaxTopics = 50
+	// clients may send at most wsClientBurst frames per wsClientWindow
+	wsClientBurst  = 20
+	wsClientWindow = 10 * time.Second
+)
+
+// wsUpgrader keeps gorilla's default origin check, which only lets pages
+// from Chirpy's own host open a socket
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// websocket topics, user and hashtag topics carry a suffix such as
+// "user:<uuid>" or "hashtag:golang"
+const (
+	wsTopicHome          = "home"
+	wsTopicNotifications = "notifications"
+	wsTopicUserPrefix    = "user:"
+	wsTopicHashtagPrefix = "hashtag:"
+)
+
+// wsFrame is the JSON envelope for every message in both directions
+type wsFrame struct {
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// wsServer tracks open sockets so shutdown can close them, as hijacked
+// connections are invisible to http.Server.Shutdown
+type wsServer struct {
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+	closed  bool
+}
+
+func newWSServer() *wsServer {
+	return &wsServer{clients: map[*wsClient]struct{}{}}
+}
+
+func (s *wsServer) add(c *wsClient) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.clients[c] = struct{}{}
+	return true
+}
+
+func (s *wsServer) remove(c *wsClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, c)
+}
+
+// Shutdown sends every open socket a going-away close frame and refuses
+// new ones
+func (s *wsServer) Shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	clients := make([]*wsClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+	for _, c := range clients {
+		c.closeWith(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+type wsClient struct {
+	conn *websocket.Conn
+	audience
+	mu        sync.Mutex
+	topics    map[string]bool
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// closeWith may be called from any goroutine, as WriteControl is safe to
+// use alongside the writer
+func (c *wsClient) closeWith(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+		c.conn.Close()
+		close(c.done)
+	})
+}
+
+// enqueue hands a frame to the writer, closing the connection when the
+// client has fallen too far behind
+func (c *wsClient) enqueue(frame wsFrame) {
+	dat, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("error marshalling websocket frame: %v", err)
+		return
+	}
+	select {
+	case c.send <- dat:
+	case <-c.done:
+	default:
+		c.closeWith(websocket.CloseTryAgainLater, "send queue full")
+	}
+}
+
+// topicFor returns the subscribed topic an event should be delivered on,
+// or "" if the client shouldn't get it
+func (c *wsClient) topicFor(event stream.Event) string {
+	if !c.canSee(event) {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if event.Type == stream.EventNotification {
+		if c.topics[wsTopicNotifications] {
+			return wsTopicNotifications
+		}
+		return ""
+	}
+	if c.topics[wsTopicHome] && c.follows(event.AuthorID) {
+		return wsTopicHome
+	}
+	if topic := wsTopicUserPrefix + event.AuthorID.String(); c.topics[topic] {
+		return topic
+	}
+	for _, tag := range event.Hashtags {
+		if topic := wsTopicHashtagPrefix + tag; c.topics[topic] {
+			return topic
+		}
+	}
+	return ""
+}
+
+func validWSTopic(topic string) bool {
+	switch {
+	case topic == wsTopicHome, topic == wsTopicNotifications:
+		return true
+	case strings.HasPrefix(topic, wsTopicUserPrefix):
+		return uuid.Validate(strings.TrimPrefix(topic, wsTopicUserPrefix)) == nil
+	case strings.HasPrefix(topic, wsTopicHashtagPrefix):
+		return validHashtag(strings.TrimPrefix(topic, wsTopicHashtagPrefix))
+	}
+	return false
+}
+
+// normalizeWSTopic lower-cases hashtag topics so they match extractHashtags
+func normalizeWSTopic(topic string) string {
+	if strings.HasPrefix(topic, wsTopicHashtagPrefix) {
+		return strings.ToLower(topic)
+	}
+	return topic
+}
+
+func (c *wsClient) handleFrame(frame wsFrame) {
+	switch frame.Type {
+	case "ping":
+		c.enqueue(wsFrame{Type: "pong"})
+	case "subscribe":
+		topic := normalizeWSTopic(frame.Topic)
+		if !validWSTopic(topic) {
+			c.enqueue(wsFrame{Type: "error", Topic: frame.Topic, Message: "unknown topic"})
+			return
+		}
+		c.mu.Lock()
+		full := len(c.topics) >= wsMaxTopics && !c.topics[topic]
+		if !full {
+			c.topics[topic] = true
+		}
+		c.mu.Unlock()
+		if full {
+			c.enqueue(wsFrame{Type: "error", Topic: frame.Topic, Message: "too many topics"})
+			return
+		}
+		c.enqueue(wsFrame{Type: "subscribed", Topic: topic})
+	case "unsubscribe":
+		topic := normalizeWSTopic(frame.Topic)
+		c.mu.Lock()
+		delete(c.topics, topic)
+		c.mu.Unlock()
+		c.enqueue(wsFrame{Type: "unsubscribed", Topic: topic})
+	default:
+		c.enqueue(wsFrame{Type: "error", Message: "unknown frame type"})
+	}
+}
+
+// writeLoop is the connection's only writer of data frames
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case dat := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, dat); err != nil {
+				c.closeWith(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.closeWith(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// pumpEvents forwards hub events matching the client's topics
+func (c *wsClient) pumpEvents(sub *stream.Subscriber) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, open := <-sub.C:
+			if !open {
+				c.closeWith(websocket.CloseTryAgainLater, "send queue full")
+				return
+			}
+			if topic := c.topicFor(event); topic != "" {
+				c.enqueue(wsFrame{Type: event.Type, Topic: topic, Data: event.Data})
+			}
+		}
+	}
+}
+
+// WSHandler upgrades to a WebSocket authenticated with the usual bearer
+// JWT. Clients send {"type":"subscribe","topic":...} frames for the home
+// timeline, a user, a hashtag or their notifications, and receive chirp,
+// tombstone and notification frames on those topics.
+func (cfg *apiConfig) WSHandler(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticate(req, scopeChirpsRead)
+	if err != nil {
+		respondToAuthError(res, err)
+		return
+	}
+	viewer, err := cfg.loadAudience(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, 500, err.Error())
+		return
+	}
+	conn, err := wsUpgrader.Upgrade(res, req, nil)
+	if err != nil {
+		// the upgrader has already answered with an error status
+		return
+	}
+	conn.SetReadLimit(wsReadLimit)
+	client := &wsClient{
+		conn:     conn,
+		audience: viewer,
+		topics:   map[string]bool{},
+		send:     make(chan []byte, wsSendQueue),
+		done:     make(chan struct{}),
+	}
+	if !cfg.Sockets.add(client) {
+		client.closeWith(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer cfg.Sockets.remove(client)
+	sub, _ := cfg.Stream.Subscribe("")
+	defer cfg.Stream.Unsubscribe(sub)
+	defer client.closeWith(websocket.CloseNormalClosure, "")
+
+	go client.writeLoop()
+	go client.pumpEvents(sub)
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	windowStart := time.Now()
+	received := 0
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if time.Since(windowStart) > wsClientWindow {
+			windowStart = time.Now()
+			received = 0
+		}
+		received++
+		if received > wsClientBurst {
+			client.closeWith(websocket.ClosePolicyViolation, "rate limit exceeded")
+			return
+		}
+		frame := wsFrame{}
+		if err := json.Unmarshal(msg, &frame); err != nil {
+			client.enqueue(wsFrame{Type: "error", Message: "invalid JSON"})
+			continue
+		}
+		client.handleFrame(frame)
+	}
+}